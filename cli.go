@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/unfor19/columbus-app/internal/aws/accounts"
+	"github.com/unfor19/columbus-app/internal/config"
+	"github.com/unfor19/columbus-app/internal/insights"
+	"github.com/unfor19/columbus-app/internal/render"
+	"github.com/unfor19/columbus-app/internal/takeover"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
+)
+
+// stringsFlag is a flag that can be repeated, such as -principal
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// exploreCommand explores a single URL without starting the server, prints
+// the mapping and findings in the -format format and exits with exitFindings
+// when a finding reaches the -fail-on severity.
+func exploreCommand(args []string) int {
+	defaults := config.Default()
+	flags := flag.NewFlagSet("explore", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: columbus-app explore [flags] <url>")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "Configuration file, YAML or JSON, defaults to COLUMBUS_CONFIG")
+	region := flags.String("region", defaults.Aws.Region, "AWS region, S3 origins use their bucket's region")
+	resolver := flags.String("resolver", strings.Join(defaults.Resolvers, ","), "DNS resolvers, host or host:port, comma separated")
+	index := flags.String("index", defaults.IndexFilePath, "Index file path of the website")
+	output := flags.String("output", "", "Write the result to a file instead of stdout")
+	formatName := flags.String("format", string(render.FormatJSON), fmt.Sprintf("Output format, one of %v", render.Formats))
+	failOn := flags.String("fail-on", string(insights.SeverityHigh), "Exit with a non-zero code on findings of this severity or higher")
+	var principals stringsFlag
+	flags.Var(&principals, "principal", "IAM principal ARN to evaluate bucket policies for, can be repeated")
+	roles := accounts.Roles{}
+	flags.Var(roles, "role", "Role chain to assume, as service=arn[,arn] or account=arn[,arn], where service is cloudfront, route53 or s3, can be repeated")
+
+	// Allow the URL before the flags, as in explore https://example.com -region us-east-1
+	var requestUrl string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		requestUrl, args = args[0], args[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if requestUrl == "" {
+		requestUrl = flags.Arg(0)
+	}
+	if requestUrl == "" {
+		// export COLUMBUS_REQUEST_URL=https://dev.sokker.info
+		requestUrl = os.Getenv("COLUMBUS_REQUEST_URL")
+	}
+	if requestUrl == "" {
+		flags.Usage()
+		return exitUsage
+	}
+	format, err := render.ParseFormat(*formatName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	threshold, err := insights.ParseSeverity(*failOn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	// Flags that are set override the configuration file and environment
+	settings, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "region":
+			settings.Aws.Region = *region
+		case "resolver":
+			settings.Resolvers = nil
+			for _, r := range strings.Split(*resolver, ",") {
+				settings.Resolvers = append(settings.Resolvers, config.ResolverAddress(strings.TrimSpace(r)))
+			}
+		case "index":
+			settings.IndexFilePath = *index
+		}
+	})
+	if err := settings.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if settings.PublicSuffixList != "" {
+		if err := cdns.LoadPublicSuffixList(settings.PublicSuffixList); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+	explorerConfig := settings.Explorer()
+	explorerConfig.Principals = principals
+	for key, chain := range roles {
+		explorerConfig.Roles[key] = chain
+	}
+
+	result, err := explore(context.Background(), explorerConfig, requestUrl, func(stage string) {
+		log.Println("Stage:", stage)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Exploration failed:", err)
+		return exitError
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := render.Render(w, format, result); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	for _, f := range result.Findings {
+		fmt.Fprintf(os.Stderr, "[%s] %s: %s\n", f.Severity, f.Insight, f.Title)
+	}
+	if max := insights.MaxSeverity(result.Findings); max != "" && max.Rank() >= threshold.Rank() {
+		return exitFindings
+	}
+	return exitOk
+}
+
+// traceCommand traces the delegation of a domain from the root, prints every
+// referral and the answers of the delegated name servers, and exits with
+// exitFindings on a lame or mismatched delegation.
+func traceCommand(args []string) int {
+	flags := flag.NewFlagSet("trace", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: columbus-app trace [flags] <domain>")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "Configuration file, YAML or JSON, defaults to COLUMBUS_CONFIG")
+	server := flags.String("server", "", "Server to start the trace at, defaults to the root servers")
+	zone := flags.String("zone", "", "Zone that should be delegated, such as the hosted zone's name")
+	nameServers := flags.String("nameservers", "", "Name servers the zone should be delegated to, comma separated")
+
+	var domainName string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		domainName, args = args[0], args[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if domainName == "" {
+		domainName = flags.Arg(0)
+	}
+	domainName = cdns.GetDomainName(domainName)
+	if domainName == "" {
+		flags.Usage()
+		return exitUsage
+	}
+
+	settings, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if *server != "" {
+		settings.TraceServer = config.ResolverAddress(*server)
+	}
+	if err := settings.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	var expected []string
+	if *nameServers != "" {
+		expected = strings.Split(*nameServers, ",")
+	}
+
+	trace, err := cdns.TraceDelegation(context.Background(), domainName, settings.TraceServer, settings.Resolvers[0])
+	for _, step := range trace.Steps {
+		fmt.Printf("%s delegated by %s to %s\n", step.Zone, step.Server, strings.Join(step.NameServers, ", "))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Trace failed:", err)
+		return exitError
+	}
+	for _, s := range trace.Servers {
+		status := "authoritative"
+		switch {
+		case s.Error != "":
+			status = s.Error
+		case !s.Authoritative:
+			status = "not authoritative, " + s.Rcode
+		}
+		fmt.Printf("%s (%s) %s, NS %s\n", s.NameServer, s.Address, status, strings.Join(s.NameServers, ", "))
+	}
+	problems := trace.Problems(*zone, expected)
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "[%s] %s\n", p.Kind, p.Message)
+	}
+	if len(problems) > 0 {
+		return exitFindings
+	}
+	return exitOk
+}
+
+// scanCommand scans every record of the hosted zones that serve a domain for
+// dangling records, prints the result as JSON and exits with exitFindings
+// when a record's risk reaches the -fail-on severity.
+func scanCommand(args []string) int {
+	defaults := config.Default()
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: columbus-app scan [flags] <zone>")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "Configuration file, YAML or JSON, defaults to COLUMBUS_CONFIG")
+	region := flags.String("region", defaults.Aws.Region, "AWS region, S3 buckets are checked in their own region")
+	output := flags.String("output", "", "Write the result to a file instead of stdout")
+	failOn := flags.String("fail-on", string(insights.SeverityHigh), "Exit with a non-zero code on records of this risk or higher")
+	roles := accounts.Roles{}
+	flags.Var(roles, "role", "Role chain to assume, as service=arn[,arn] or account=arn[,arn], where service is cloudfront, route53 or s3, can be repeated")
+
+	var zone string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		zone, args = args[0], args[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if zone == "" {
+		zone = flags.Arg(0)
+	}
+	zone = cdns.GetDomainName(zone)
+	if zone == "" {
+		flags.Usage()
+		return exitUsage
+	}
+	threshold, err := insights.ParseSeverity(*failOn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	settings, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "region" {
+			settings.Aws.Region = *region
+		}
+	})
+	if err := settings.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if settings.PublicSuffixList != "" {
+		if err := cdns.LoadPublicSuffixList(settings.PublicSuffixList); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+	explorerConfig := settings.Explorer()
+	for key, chain := range roles {
+		explorerConfig.Roles[key] = chain
+	}
+
+	result, err := takeover.Scan(context.Background(), explorerConfig, zone)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Scan failed:", err)
+		return exitError
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		defer f.Close()
+		w = f
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	status := exitOk
+	for _, f := range result.Findings {
+		fmt.Fprintf(os.Stderr, "[%s] %s %s -> %s: %s\n", f.Risk, f.Record.Name, f.Record.Type, f.Target, f.Message)
+		if f.Risk.Rank() >= threshold.Rank() {
+			status = exitFindings
+		}
+	}
+	return status
+}
@@ -0,0 +1,199 @@
+// Package accounts explores resources that live in other AWS accounts, by
+// assuming chains of IAM roles per service or per account.
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	cs3 "github.com/unfor19/columbus-app/internal/aws/service/s3"
+)
+
+const (
+	ServiceCloudFront = "cloudfront"
+	ServiceRoute53    = "route53"
+	ServiceS3         = "s3"
+)
+
+const sessionName = "columbus"
+
+var accountIdPattern = regexp.MustCompile(`^\d{12}$`)
+var roleArnPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/.+$`)
+
+// Roles maps a service name, or an account id, to a chain of role ARNs that
+// are assumed in order, each role with the credentials of the previous one.
+type Roles map[string][]string
+
+// ParseRole parses a key=chain pair, where key is cloudfront, route53, s3 or
+// an account id, and chain is a comma separated list of role ARNs.
+func ParseRole(s string) (key string, chain []string, err error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return "", nil, fmt.Errorf("invalid role %q, expected service=arn[,arn] or account=arn[,arn]", s)
+	}
+	key = strings.ToLower(strings.TrimSpace(parts[0]))
+	switch key {
+	case ServiceCloudFront, ServiceRoute53, ServiceS3:
+	default:
+		if !accountIdPattern.MatchString(key) {
+			return "", nil, fmt.Errorf("invalid role key %q, expected %s, %s, %s or an account id", key, ServiceCloudFront, ServiceRoute53, ServiceS3)
+		}
+	}
+	for _, arn := range strings.Split(parts[1], ",") {
+		arn = strings.TrimSpace(arn)
+		if !roleArnPattern.MatchString(arn) {
+			return "", nil, fmt.Errorf("invalid role ARN %q for %s", arn, key)
+		}
+		chain = append(chain, arn)
+	}
+	return key, chain, nil
+}
+
+// ParseRoles parses semicolon separated key=chain pairs, as in the
+// COLUMBUS_ROLES environment variable.
+func ParseRoles(s string) (Roles, error) {
+	roles := Roles{}
+	for _, pair := range strings.Split(s, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		if err := roles.Set(pair); err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+func (r Roles) String() string {
+	keys := make([]string, 0, len(r))
+	for key := range r {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + strings.Join(r[key], ",")
+	}
+	return strings.Join(pairs, ";")
+}
+
+// Set adds a key=chain pair, so Roles can be used as a repeatable flag
+func (r Roles) Set(s string) error {
+	key, chain, err := ParseRole(s)
+	if err != nil {
+		return err
+	}
+	r[key] = chain
+	return nil
+}
+
+// accounts returns the account ids that have a role chain, sorted
+func (r Roles) accounts() []string {
+	var ids []string
+	for key := range r {
+		if accountIdPattern.MatchString(key) {
+			ids = append(ids, key)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// AssumeChain returns a copy of cfg whose credentials assume each role of the
+// chain in order.
+func AssumeChain(cfg aws.Config, chain []string) aws.Config {
+	for _, arn := range chain {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), arn, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = sessionName
+		})
+		cfg = cfg.Copy()
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	return cfg
+}
+
+// Accounts hands out the AWS configuration of the account that owns a
+// resource. Assumed credentials are cached, so each chain is assumed once
+// per exploration.
+type Accounts struct {
+	base    aws.Config
+	roles   Roles
+	mu      sync.Mutex
+	configs map[string]aws.Config
+	buckets map[string]aws.Config
+}
+
+func New(base aws.Config, roles Roles) *Accounts {
+	return &Accounts{
+		base:    base,
+		roles:   roles,
+		configs: map[string]aws.Config{},
+		buckets: map[string]aws.Config{},
+	}
+}
+
+// Config returns the configuration of a service or account key, or the base
+// configuration when it has no role chain.
+func (a *Accounts) Config(key string) aws.Config {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	chain, ok := a.roles[key]
+	if !ok {
+		return a.base
+	}
+	cfg, ok := a.configs[key]
+	if !ok {
+		cfg = AssumeChain(a.base, chain)
+		a.configs[key] = cfg
+	}
+	return cfg
+}
+
+// Candidates returns the configurations a service's resources may be found
+// with, the service's own chain, or the base configuration followed by the
+// configured accounts.
+func (a *Accounts) Candidates(service string) []aws.Config {
+	if _, ok := a.roles[service]; ok {
+		return []aws.Config{a.Config(service)}
+	}
+	candidates := []aws.Config{a.base}
+	for _, id := range a.roles.accounts() {
+		candidates = append(candidates, a.Config(id))
+	}
+	return candidates
+}
+
+// Bucket returns the configuration of the account that owns a bucket, the
+// first candidate that can HeadBucket it, for clients of the bucket's region.
+func (a *Accounts) Bucket(ctx context.Context, bucketName string, region string) aws.Config {
+	a.mu.Lock()
+	cfg, ok := a.buckets[bucketName]
+	a.mu.Unlock()
+	if ok {
+		return cs3.ConfigForRegion(cfg, region)
+	}
+
+	candidates := a.Candidates(ServiceS3)
+	cfg = candidates[0]
+	if len(candidates) > 1 {
+		for i, candidate := range candidates {
+			if cs3.GetS3BucketExists(ctx, cs3.ConfigForRegion(candidate, region), bucketName) {
+				log.Println("Found bucket", bucketName, "with candidate account", i)
+				cfg = candidate
+				break
+			}
+		}
+	}
+	a.mu.Lock()
+	a.buckets[bucketName] = cfg
+	a.mu.Unlock()
+	return cs3.ConfigForRegion(cfg, region)
+}
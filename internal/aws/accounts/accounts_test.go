@@ -0,0 +1,77 @@
+package accounts
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	edgeRole     = "arn:aws:iam::111111111111:role/columbus"
+	workloadRole = "arn:aws:iam::222222222222:role/columbus"
+	dnsRole      = "arn:aws:iam::333333333333:role/columbus"
+)
+
+func TestParseRoles(t *testing.T) {
+	roles, err := ParseRoles("cloudfront=" + edgeRole + "; 333333333333=" + edgeRole + "," + dnsRole + ";")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Roles{
+		ServiceCloudFront: {edgeRole},
+		"333333333333":    {edgeRole, dnsRole},
+	}
+	if !reflect.DeepEqual(roles, expected) {
+		t.Errorf("expected %v, got %v", expected, roles)
+	}
+	if s := roles.String(); s != "333333333333="+edgeRole+","+dnsRole+";cloudfront="+edgeRole {
+		t.Errorf("unexpected String() %q", s)
+	}
+
+	for _, invalid := range []string{
+		edgeRole,
+		"lambda=" + edgeRole,
+		"12345=" + edgeRole,
+		"s3=arn:aws:iam::111111111111:user/columbus",
+		"s3=" + edgeRole + ",",
+	} {
+		if _, err := ParseRoles(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	base := aws.Config{Region: "eu-west-1", Credentials: aws.AnonymousCredentials{}}
+	a := New(base, Roles{
+		ServiceCloudFront: {edgeRole},
+		"333333333333":    {dnsRole},
+		"222222222222":    {edgeRole, workloadRole},
+	})
+
+	if cfg := a.Config(ServiceRoute53); cfg.Credentials != base.Credentials {
+		t.Error("expected the base credentials for a service without roles")
+	}
+	cloudFront := a.Config(ServiceCloudFront)
+	if cloudFront.Credentials == base.Credentials {
+		t.Error("expected assumed credentials for cloudfront")
+	}
+	if a.Config(ServiceCloudFront).Credentials != cloudFront.Credentials {
+		t.Error("expected the assumed credentials to be cached")
+	}
+	if candidates := a.Candidates(ServiceCloudFront); len(candidates) != 1 || candidates[0].Credentials != cloudFront.Credentials {
+		t.Errorf("expected only the cloudfront chain, got %d candidates", len(candidates))
+	}
+
+	candidates := a.Candidates(ServiceS3)
+	if len(candidates) != 3 {
+		t.Fatalf("expected the base and 2 accounts, got %d candidates", len(candidates))
+	}
+	expected := []aws.CredentialsProvider{base.Credentials, a.Config("222222222222").Credentials, a.Config("333333333333").Credentials}
+	for i, cfg := range candidates {
+		if cfg.Credentials != expected[i] {
+			t.Errorf("unexpected credentials of candidate %d", i)
+		}
+	}
+}
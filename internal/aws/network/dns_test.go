@@ -1,15 +1,15 @@
 package awsnetwork
 
 import (
+	"context"
 	"log"
-	"os"
 	"testing"
 
 	cdns "github.com/unfor19/columbus-app/pkg/dns"
-	"github.com/unfor19/columbus-app/pkg/traffic"
 )
 
-func testDns(r string) (string, string) {
+func testDns(t *testing.T, r string) (string, string) {
+	t.Helper()
 	domainName := cdns.GetDomainName(r)
 	if domainName == "" {
 		return "", ""
@@ -19,20 +19,15 @@ func testDns(r string) (string, string) {
 	awsIpRangesUrl := "https://ip-ranges.amazonaws.com/ip-ranges.json"
 	targetIpAddress := cdns.GetTargetIPAddress(domainName, dnsServer)
 	if targetIpAddress == nil {
-		log.Fatalln("Failed to resolve Target IP Address")
+		t.Skipf("Failed to resolve the Target IP Address of %s, no network?", domainName)
 	}
 	log.Println("Target IP Address:", targetIpAddress)
-	if _, err := os.Stat(awsIpRangesFilePath); os.IsNotExist(err) {
-		err = traffic.DownloadFile(awsIpRangesFilePath, awsIpRangesUrl)
-		if err != nil {
-			log.Fatal(err)
-		}
-	} else {
-		// Exists
-		log.Println("Found AWS ip-ranges.json, skipping download:", awsIpRangesFilePath)
+	index, err := SharedIpRangesManager(awsIpRangesFilePath, awsIpRangesUrl, 0).Index(context.Background())
+	if err != nil {
+		t.Skipf("Failed to load the AWS ip ranges, no network? %v", err)
 	}
 
-	targetService := GetTargetAwsService(targetIpAddress.String(), awsIpRangesFilePath)
+	targetService := index.TargetService(targetIpAddress.String())
 	if targetService == "" {
 		return "", ""
 	}
@@ -43,7 +38,7 @@ func testDns(r string) (string, string) {
 func TestCloudFrontIp(t *testing.T) {
 	r := "https://dev.sokker.info"
 	s := "CLOUDFRONT"
-	domainName, targetAwsService := testDns(r)
+	domainName, targetAwsService := testDns(t, r)
 	if targetAwsService != s {
 		t.Fatal("Domain name", domainName, "Does not match service type", targetAwsService)
 	}
@@ -52,7 +47,7 @@ func TestCloudFrontIp(t *testing.T) {
 func TestS3Ip(t *testing.T) {
 	r := "https://s3.eu-west-1.amazonaws.com"
 	s := "S3"
-	domainName, targetAwsService := testDns(r)
+	domainName, targetAwsService := testDns(t, r)
 	if targetAwsService != s {
 		t.Fatal("Domain name", domainName, "Does not match service type", targetAwsService)
 	}
@@ -61,10 +56,8 @@ func TestS3Ip(t *testing.T) {
 func TestApiGatewayIp(t *testing.T) {
 	r := "https://lwpcc2dff2.execute-api.eu-west-1.amazonaws.com"
 	s := "API_GATEWAY"
-	s2 := "EC2"
-	// TODO: Find out why ApiGateway IP matches EC2 IP
-	domainName, targetAwsService := testDns(r)
-	if targetAwsService != s && targetAwsService != s2 {
+	domainName, targetAwsService := testDns(t, r)
+	if targetAwsService != s {
 		t.Fatal("Domain name", domainName, "Does not match service type", targetAwsService)
 	}
 }
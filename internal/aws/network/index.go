@@ -0,0 +1,133 @@
+package awsnetwork
+
+import (
+	"net"
+)
+
+// The AMAZON service is the superset of all services, its prefixes overlap
+// the prefixes of the specific service.
+const ServiceAmazon = "AMAZON"
+
+// IpRangeMatch is a published prefix that contains an IP address
+type IpRangeMatch struct {
+	Prefix             string
+	Service            string
+	Region             string
+	NetworkBorderGroup string
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	// matches of the prefix that ends at this node, AMAZON last
+	matches []IpRangeMatch
+}
+
+func bitAt(ip net.IP, i int) byte {
+	return ip[i/8] >> (7 - uint(i%8)) & 1
+}
+
+func (n *trieNode) insert(ip net.IP, ones int, match IpRangeMatch) {
+	node := n
+	for i := 0; i < ones; i++ {
+		bit := bitAt(ip, i)
+		if node.children[bit] == nil {
+			node.children[bit] = &trieNode{}
+		}
+		node = node.children[bit]
+	}
+	i := len(node.matches)
+	if match.Service != ServiceAmazon {
+		for i > 0 && node.matches[i-1].Service == ServiceAmazon {
+			i--
+		}
+	}
+	node.matches = append(node.matches, IpRangeMatch{})
+	copy(node.matches[i+1:], node.matches[i:])
+	node.matches[i] = match
+}
+
+// lookup returns the matches of every prefix on the path of ip, the most
+// specific first
+func (n *trieNode) lookup(ip net.IP) []IpRangeMatch {
+	var path []*trieNode
+	for i, node := 0, n; node != nil; i++ {
+		path = append(path, node)
+		if i == len(ip)*8 {
+			break
+		}
+		node = node.children[bitAt(ip, i)]
+	}
+	var matches []IpRangeMatch
+	for i := len(path) - 1; i >= 0; i-- {
+		matches = append(matches, path[i].matches...)
+	}
+	return matches
+}
+
+// IpRangesIndex is a prefix trie of the IPv4 and IPv6 ranges, it is built
+// once per ip-ranges.json and is safe for concurrent lookups.
+type IpRangesIndex struct {
+	ipv4 trieNode
+	ipv6 trieNode
+}
+
+func (idx *IpRangesIndex) add(prefix string, match IpRangeMatch) {
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return
+	}
+	ones, _ := ipNet.Mask.Size()
+	if len(ipNet.IP) == net.IPv4len {
+		idx.ipv4.insert(ipNet.IP, ones, match)
+	} else {
+		idx.ipv6.insert(ipNet.IP, ones, match)
+	}
+}
+
+func NewIpRangesIndex(ranges AwsIpRanges) *IpRangesIndex {
+	idx := &IpRangesIndex{}
+	for _, p := range ranges.Prefixes {
+		idx.add(p.IpPrefix, IpRangeMatch{
+			Prefix:             p.IpPrefix,
+			Service:            p.Service,
+			Region:             p.Region,
+			NetworkBorderGroup: p.NetworkBorderGroup,
+		})
+	}
+	for _, p := range ranges.Ipv6Prefixes {
+		idx.add(p.Ipv6Prefix, IpRangeMatch{
+			Prefix:             p.Ipv6Prefix,
+			Service:            p.Service,
+			Region:             p.Region,
+			NetworkBorderGroup: p.NetworkBorderGroup,
+		})
+	}
+	return idx
+}
+
+// Lookup returns every prefix that contains ip, the most specific first.
+// Within the same prefix, the specific services come before AMAZON.
+func (idx *IpRangesIndex) Lookup(ip net.IP) []IpRangeMatch {
+	if ip4 := ip.To4(); ip4 != nil {
+		return idx.ipv4.lookup(ip4)
+	}
+	if ip16 := ip.To16(); ip16 != nil {
+		return idx.ipv6.lookup(ip16)
+	}
+	return nil
+}
+
+// TargetService returns the service of the most specific prefix that
+// contains ip, other than AMAZON
+func (idx *IpRangesIndex) TargetService(ip string) string {
+	parsedIp := net.ParseIP(ip)
+	if parsedIp == nil {
+		return ""
+	}
+	for _, m := range idx.Lookup(parsedIp) {
+		if m.Service != ServiceAmazon {
+			return m.Service
+		}
+	}
+	return ""
+}
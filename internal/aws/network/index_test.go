@@ -0,0 +1,96 @@
+package awsnetwork
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+var testRanges = AwsIpRanges{
+	SyncToken:  "1659355200",
+	CreateDate: "2022-08-01-12-00-00",
+	Prefixes: []AwsIpRangesPrefix{
+		{IpPrefix: "3.248.0.0/13", Region: "eu-west-1", Service: "AMAZON", NetworkBorderGroup: "eu-west-1"},
+		{IpPrefix: "3.248.0.0/13", Region: "eu-west-1", Service: "EC2", NetworkBorderGroup: "eu-west-1"},
+		{IpPrefix: "3.251.110.208/28", Region: "eu-west-1", Service: "API_GATEWAY", NetworkBorderGroup: "eu-west-1"},
+		{IpPrefix: "3.251.110.0/24", Region: "eu-west-1", Service: "AMAZON", NetworkBorderGroup: "eu-west-1"},
+		{IpPrefix: "52.46.0.0/18", Region: "GLOBAL", Service: "CLOUDFRONT", NetworkBorderGroup: "GLOBAL"},
+	},
+	Ipv6Prefixes: []AwsIpv6RangesPrefix{
+		{Ipv6Prefix: "2600:9000::/28", Region: "GLOBAL", Service: "AMAZON", NetworkBorderGroup: "GLOBAL"},
+		{Ipv6Prefix: "2600:9000::/28", Region: "GLOBAL", Service: "CLOUDFRONT", NetworkBorderGroup: "GLOBAL"},
+		{Ipv6Prefix: "2a05:d018::/35", Region: "eu-west-1", Service: "EC2", NetworkBorderGroup: "eu-west-1"},
+	},
+}
+
+func TestIpRangesIndexLookup(t *testing.T) {
+	idx := NewIpRangesIndex(testRanges)
+	tests := []struct {
+		ip       string
+		prefixes []string
+		services []string
+		service  string
+	}{
+		{
+			ip:       "3.251.110.210",
+			prefixes: []string{"3.251.110.208/28", "3.251.110.0/24", "3.248.0.0/13", "3.248.0.0/13"},
+			services: []string{"API_GATEWAY", "AMAZON", "EC2", "AMAZON"},
+			service:  "API_GATEWAY",
+		},
+		{
+			ip:       "3.251.110.1",
+			prefixes: []string{"3.251.110.0/24", "3.248.0.0/13", "3.248.0.0/13"},
+			services: []string{"AMAZON", "EC2", "AMAZON"},
+			service:  "EC2",
+		},
+		{
+			ip:       "52.46.10.1",
+			prefixes: []string{"52.46.0.0/18"},
+			services: []string{"CLOUDFRONT"},
+			service:  "CLOUDFRONT",
+		},
+		{
+			ip:       "2600:9000:2000::1",
+			prefixes: []string{"2600:9000::/28", "2600:9000::/28"},
+			services: []string{"CLOUDFRONT", "AMAZON"},
+			service:  "CLOUDFRONT",
+		},
+		{
+			ip:       "2a05:d018:100::1",
+			prefixes: []string{"2a05:d018::/35"},
+			services: []string{"EC2"},
+			service:  "EC2",
+		},
+		{ip: "1.1.1.1"},
+		{ip: "2001:db8::1"},
+		// IPv4-mapped IPv6 addresses are looked up as IPv4
+		{
+			ip:       "::ffff:52.46.10.1",
+			prefixes: []string{"52.46.0.0/18"},
+			services: []string{"CLOUDFRONT"},
+			service:  "CLOUDFRONT",
+		},
+	}
+	for _, test := range tests {
+		matches := idx.Lookup(net.ParseIP(test.ip))
+		var prefixes, services []string
+		for _, m := range matches {
+			prefixes = append(prefixes, m.Prefix)
+			services = append(services, m.Service)
+		}
+		if !reflect.DeepEqual(prefixes, test.prefixes) || !reflect.DeepEqual(services, test.services) {
+			t.Errorf("%s: expected %v %v, got %v %v", test.ip, test.prefixes, test.services, prefixes, services)
+		}
+		if service := idx.TargetService(test.ip); service != test.service {
+			t.Errorf("%s: expected service %q, got %q", test.ip, test.service, service)
+		}
+	}
+
+	m := idx.Lookup(net.ParseIP("2a05:d018:100::1"))[0]
+	if m.Region != "eu-west-1" || m.NetworkBorderGroup != "eu-west-1" {
+		t.Errorf("unexpected match %+v", m)
+	}
+	if service := idx.TargetService("not an ip"); service != "" {
+		t.Errorf("expected no service, got %q", service)
+	}
+}
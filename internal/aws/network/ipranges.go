@@ -0,0 +1,335 @@
+package awsnetwork
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/unfor19/columbus-app/pkg/traffic"
+)
+
+// createDate format of ip-ranges.json, in UTC
+const createDateLayout = "2006-01-02-15-04-05"
+
+// The published file is a few MB, anything much larger is not ip-ranges.json
+const maxIpRangesSize = 64 << 20
+
+// A failed refresh of stale ranges is retried after this interval, the
+// previous ranges are served meanwhile
+const refreshRetryInterval = time.Minute
+
+var ErrNoIpRanges = errors.New("no AWS ip ranges loaded")
+
+// ParseAwsIpRanges parses and validates ip-ranges.json, so an error page or a
+// truncated download is never used.
+func ParseAwsIpRanges(b []byte) (AwsIpRanges, error) {
+	var ranges AwsIpRanges
+	if err := json.Unmarshal(b, &ranges); err != nil {
+		return ranges, fmt.Errorf("invalid ip ranges: %w", err)
+	}
+	if _, err := strconv.ParseInt(ranges.SyncToken, 10, 64); err != nil {
+		return ranges, fmt.Errorf("invalid ip ranges syncToken %q", ranges.SyncToken)
+	}
+	if _, err := time.Parse(createDateLayout, ranges.CreateDate); err != nil {
+		return ranges, fmt.Errorf("invalid ip ranges createDate %q", ranges.CreateDate)
+	}
+	if len(ranges.Prefixes) == 0 {
+		return ranges, errors.New("invalid ip ranges, no prefixes")
+	}
+	for _, p := range ranges.Prefixes {
+		if _, _, err := net.ParseCIDR(p.IpPrefix); err != nil {
+			return ranges, fmt.Errorf("invalid ip ranges prefix %q", p.IpPrefix)
+		}
+	}
+	for _, p := range ranges.Ipv6Prefixes {
+		if _, _, err := net.ParseCIDR(p.Ipv6Prefix); err != nil {
+			return ranges, fmt.Errorf("invalid ip ranges ipv6 prefix %q", p.Ipv6Prefix)
+		}
+	}
+	return ranges, nil
+}
+
+// CreatedAt is the time the ranges were published
+func (r AwsIpRanges) CreatedAt() time.Time {
+	t, _ := time.Parse(createDateLayout, r.CreateDate)
+	return t
+}
+
+func (r AwsIpRanges) syncToken() int64 {
+	token, _ := strconv.ParseInt(r.SyncToken, 10, 64)
+	return token
+}
+
+// IpRangesManager keeps the parsed AWS ip ranges in memory, and refreshes
+// the cached file with conditional requests once it is older than the
+// refresh interval.
+type IpRangesManager struct {
+	filePath        string
+	url             string
+	refreshInterval time.Duration
+	retryInterval   time.Duration
+	client          *http.Client
+
+	// refreshMu serializes refreshes, mu guards the fields below
+	refreshMu    sync.Mutex
+	mu           sync.RWMutex
+	ranges       AwsIpRanges
+	index        *IpRangesIndex
+	etag         string
+	lastModified string
+	checkedAt    time.Time
+	failedAt     time.Time
+}
+
+func NewIpRangesManager(filePath string, url string, refreshInterval time.Duration) *IpRangesManager {
+	return &IpRangesManager{
+		filePath:        filePath,
+		url:             url,
+		refreshInterval: refreshInterval,
+		retryInterval:   refreshRetryInterval,
+		client:          &http.Client{Timeout: time.Minute},
+	}
+}
+
+type sharedIpRangesKey struct {
+	filePath        string
+	url             string
+	refreshInterval time.Duration
+}
+
+var (
+	sharedIpRangesMu sync.Mutex
+	sharedIpRanges   = map[sharedIpRangesKey]*IpRangesManager{}
+)
+
+// SharedIpRangesManager returns the manager of filePath, url and
+// refreshInterval, which is shared by every exploration of the process with
+// the same settings, so the file is parsed once.
+func SharedIpRangesManager(filePath string, url string, refreshInterval time.Duration) *IpRangesManager {
+	sharedIpRangesMu.Lock()
+	defer sharedIpRangesMu.Unlock()
+	key := sharedIpRangesKey{filePath: filePath, url: url, refreshInterval: refreshInterval}
+	m, ok := sharedIpRanges[key]
+	if !ok {
+		m = NewIpRangesManager(filePath, url, refreshInterval)
+		sharedIpRanges[key] = m
+	}
+	return m
+}
+
+func (m *IpRangesManager) loaded() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ranges.SyncToken != ""
+}
+
+// stale reports whether the ranges are older than the refresh interval and
+// no refresh failed within the retry interval
+func (m *IpRangesManager) stale() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.refreshInterval <= 0 || time.Since(m.checkedAt) <= m.refreshInterval {
+		return false
+	}
+	return time.Since(m.failedAt) > m.retryInterval
+}
+
+// loadFile reads the cached file, its modification time is when it was last
+// known to be up to date.
+func (m *IpRangesManager) loadFile() error {
+	b, err := ioutil.ReadFile(m.filePath)
+	if err != nil {
+		return err
+	}
+	ranges, err := ParseAwsIpRanges(b)
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.filePath, err)
+	}
+	checkedAt := time.Now()
+	if info, err := os.Stat(m.filePath); err == nil {
+		checkedAt = info.ModTime()
+	}
+	m.setRanges(ranges)
+	m.mu.Lock()
+	m.checkedAt = checkedAt
+	m.mu.Unlock()
+	log.Println("Loaded AWS ip ranges", ranges.SyncToken, "created at", ranges.CreateDate, "from", m.filePath)
+	return nil
+}
+
+// Refresh downloads the ranges unless they did not change since the last
+// download, the cached file is replaced only with valid and newer ranges.
+func (m *IpRangesManager) Refresh(ctx context.Context) (updated bool, err error) {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+	return m.refresh(ctx)
+}
+
+// refresh must be called with m.refreshMu held.
+func (m *IpRangesManager) refresh(ctx context.Context) (updated bool, err error) {
+	defer func() {
+		if err != nil {
+			m.mu.Lock()
+			m.failedAt = time.Now()
+			m.mu.Unlock()
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.url, nil)
+	if err != nil {
+		return false, err
+	}
+	m.mu.RLock()
+	current := m.ranges
+	if m.etag != "" {
+		req.Header.Set("If-None-Match", m.etag)
+	}
+	if m.lastModified != "" {
+		req.Header.Set("If-Modified-Since", m.lastModified)
+	} else if createdAt := current.CreatedAt(); !createdAt.IsZero() {
+		req.Header.Set("If-Modified-Since", createdAt.UTC().Format(http.TimeFormat))
+	}
+	m.mu.RUnlock()
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		log.Println("AWS ip ranges are up to date", current.SyncToken)
+		m.markChecked(resp)
+		// The file's modification time is when it was last checked
+		if err := os.Chtimes(m.filePath, time.Now(), time.Now()); err != nil {
+			log.Println(err)
+		}
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("failed to download %s, status code %d", m.url, resp.StatusCode)
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxIpRangesSize))
+	if err != nil {
+		return false, err
+	}
+	ranges, err := ParseAwsIpRanges(b)
+	if err != nil {
+		return false, err
+	}
+	if ranges.syncToken() < current.syncToken() {
+		log.Println("Ignoring AWS ip ranges", ranges.SyncToken, "older than", current.SyncToken)
+		m.markChecked(resp)
+		return false, nil
+	}
+	err = traffic.WriteFileAtomic(m.filePath, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	m.setRanges(ranges)
+	m.markChecked(resp)
+	log.Println("Downloaded AWS ip ranges", ranges.SyncToken, "created at", ranges.CreateDate)
+	return ranges.SyncToken != current.SyncToken, nil
+}
+
+// setRanges swaps the ranges and their index, which is built outside of the
+// lock so lookups are not blocked
+func (m *IpRangesManager) setRanges(ranges AwsIpRanges) {
+	index := NewIpRangesIndex(ranges)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ranges = ranges
+	m.index = index
+}
+
+func (m *IpRangesManager) markChecked(resp *http.Response) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checkedAt = time.Now()
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		m.etag = etag
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		m.lastModified = lastModified
+	}
+}
+
+// Ranges returns the ranges in memory, loading the cached file or
+// downloading it on first use, and refreshing them when they are stale. A
+// failed refresh keeps the previous ranges and is not retried before the
+// retry interval.
+func (m *IpRangesManager) Ranges(ctx context.Context) (AwsIpRanges, error) {
+	if err := m.ensure(ctx); err != nil {
+		return AwsIpRanges{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ranges, nil
+}
+
+// Index returns the index of the ranges returned by Ranges
+func (m *IpRangesManager) Index(ctx context.Context) (*IpRangesIndex, error) {
+	if err := m.ensure(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.index, nil
+}
+
+func (m *IpRangesManager) ensure(ctx context.Context) error {
+	if m.loaded() && !m.stale() {
+		return nil
+	}
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+	if !m.loaded() {
+		if err := m.loadFile(); err != nil && !os.IsNotExist(err) {
+			log.Println("Ignoring the cached AWS ip ranges:", err)
+		}
+	}
+	// The cached file may be fresh, or a concurrent exploration refreshed it
+	if m.loaded() && !m.stale() {
+		return nil
+	}
+	if _, err := m.refresh(ctx); err != nil {
+		if !m.loaded() {
+			return fmt.Errorf("%w: %v", ErrNoIpRanges, err)
+		}
+		log.Println("Failed to refresh AWS ip ranges, using the previous ranges:", err)
+	}
+	return nil
+}
+
+// Run refreshes the ranges every refresh interval until ctx is done
+func (m *IpRangesManager) Run(ctx context.Context) {
+	if m.refreshInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(m.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.Refresh(ctx); err != nil {
+				log.Println("Failed to refresh AWS ip ranges:", err)
+			}
+		}
+	}
+}
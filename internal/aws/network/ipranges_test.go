@@ -0,0 +1,187 @@
+package awsnetwork
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testIpRanges(syncToken string) string {
+	return fmt.Sprintf(`{
+  "syncToken": "%s",
+  "createDate": "2022-08-01-12-00-00",
+  "prefixes": [
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "AMAZON", "network_border_group": "ap-northeast-2"},
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "S3", "network_border_group": "ap-northeast-2"}
+  ]
+}`, syncToken)
+}
+
+type ipRangesServer struct {
+	mu       sync.Mutex
+	status   int
+	body     string
+	etag     string
+	requests []*http.Request
+}
+
+func (s *ipRangesServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	if s.etag != "" && r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if s.etag != "" {
+		w.Header().Set("ETag", s.etag)
+	}
+	w.WriteHeader(s.status)
+	fmt.Fprint(w, s.body)
+}
+
+func (s *ipRangesServer) set(status int, body string, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.body, s.etag = status, body, etag
+}
+
+func (s *ipRangesServer) last() *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+func TestParseAwsIpRanges(t *testing.T) {
+	if _, err := ParseAwsIpRanges([]byte(testIpRanges("1659355200"))); err != nil {
+		t.Fatal(err)
+	}
+	for _, invalid := range []string{
+		"<html>Access Denied</html>",
+		testIpRanges("latest"),
+		`{"syncToken": "1", "createDate": "2022-08-01-12-00-00", "prefixes": []}`,
+		`{"syncToken": "1", "createDate": "2022-08-01-12-00-00", "prefixes": [{"ip_prefix": "3.5.140.0"}]}`,
+		testIpRanges("1659355200")[:100],
+	} {
+		if _, err := ParseAwsIpRanges([]byte(invalid)); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestIpRangesManager(t *testing.T) {
+	server := &ipRangesServer{status: http.StatusOK, body: testIpRanges("1659355200"), etag: `"v1"`}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	filePath := filepath.Join(t.TempDir(), "ip-ranges.json")
+	m := NewIpRangesManager(filePath, ts.URL, time.Hour)
+	ctx := context.Background()
+
+	ranges, err := m.Ranges(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service := NewIpRangesIndex(ranges).TargetService("3.5.140.2"); service != "S3" {
+		t.Errorf("expected S3, got %q", service)
+	}
+	if b, err := ioutil.ReadFile(filePath); err != nil || string(b) != server.body {
+		t.Fatalf("expected the downloaded file, got %q %v", b, err)
+	}
+
+	// The ranges are in memory until they are stale
+	if _, err := m.Ranges(ctx); err != nil || len(server.requests) != 1 {
+		t.Fatalf("expected a single request, got %d %v", len(server.requests), err)
+	}
+
+	updated, err := m.Refresh(ctx)
+	if err != nil || updated {
+		t.Fatalf("expected the ranges to be up to date, got %v %v", updated, err)
+	}
+	r := server.last()
+	if r.Header.Get("If-None-Match") != `"v1"` || r.Header.Get("If-Modified-Since") != "Mon, 01 Aug 2022 12:00:00 GMT" {
+		t.Errorf("expected a conditional request, got %v", r.Header)
+	}
+
+	// Failed downloads, invalid and older ranges do not replace the file
+	for _, response := range []struct {
+		status int
+		body   string
+	}{
+		{http.StatusNotFound, "Not Found"},
+		{http.StatusOK, "<html>Access Denied</html>"},
+		{http.StatusOK, testIpRanges("1659355199")},
+	} {
+		server.set(response.status, response.body, "")
+		if updated, _ := m.Refresh(ctx); updated {
+			t.Errorf("unexpected update with %d %q", response.status, response.body)
+		}
+		if b, _ := ioutil.ReadFile(filePath); string(b) != testIpRanges("1659355200") {
+			t.Errorf("expected the file to be kept with %d %q", response.status, response.body)
+		}
+	}
+
+	server.set(http.StatusOK, testIpRanges("1659355201"), `"v2"`)
+	if updated, err := m.Refresh(ctx); err != nil || !updated {
+		t.Fatalf("expected newer ranges, got %v %v", updated, err)
+	}
+
+	// A new manager loads the cached file without downloading it
+	requests := len(server.requests)
+	ranges, err = NewIpRangesManager(filePath, ts.URL, time.Hour).Ranges(ctx)
+	if err != nil || ranges.SyncToken != "1659355201" || len(server.requests) != requests {
+		t.Errorf("expected the cached ranges, got %q %v", ranges.SyncToken, err)
+	}
+}
+
+func TestIpRangesManagerRetry(t *testing.T) {
+	server := &ipRangesServer{status: http.StatusOK, body: testIpRanges("1659355200")}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	m := NewIpRangesManager(filepath.Join(t.TempDir(), "ip-ranges.json"), ts.URL, time.Millisecond)
+	ctx := context.Background()
+	if _, err := m.Ranges(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed refresh of stale ranges serves the previous ranges and is not
+	// retried by the next lookups
+	time.Sleep(10 * time.Millisecond)
+	server.set(http.StatusServiceUnavailable, "Service Unavailable", "")
+	for i := 0; i < 3; i++ {
+		if ranges, err := m.Ranges(ctx); err != nil || ranges.SyncToken != "1659355200" {
+			t.Fatalf("expected the previous ranges, got %q %v", ranges.SyncToken, err)
+		}
+	}
+	if len(server.requests) != 2 {
+		t.Errorf("expected a single refresh attempt, got %d requests", len(server.requests)-1)
+	}
+}
+
+func TestSharedIpRangesManager(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "ip-ranges.json")
+	m := SharedIpRangesManager(filePath, "https://example.com/ip-ranges.json", time.Hour)
+	if SharedIpRangesManager(filePath, "https://example.com/ip-ranges.json", time.Hour) != m {
+		t.Error("expected the same manager for the same settings")
+	}
+	if other := SharedIpRangesManager(filePath, "https://example.org/ip-ranges.json", time.Hour); other == m || other.url != "https://example.org/ip-ranges.json" {
+		t.Error("expected another manager for another url")
+	}
+	if SharedIpRangesManager(filePath, "https://example.com/ip-ranges.json", time.Minute) == m {
+		t.Error("expected another manager for another refresh interval")
+	}
+}
+
+func TestIpRangesManagerUnavailable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+	m := NewIpRangesManager(filepath.Join(t.TempDir(), "ip-ranges.json"), ts.URL, time.Hour)
+	if _, err := m.Ranges(context.Background()); err == nil {
+		t.Error("expected an error without ip ranges")
+	}
+}
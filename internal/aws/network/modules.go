@@ -7,8 +7,16 @@ type AwsIpRangesPrefix struct {
 	NetworkBorderGroup string `json:"network_border_group"`
 }
 
+type AwsIpv6RangesPrefix struct {
+	Ipv6Prefix         string `json:"ipv6_prefix"`
+	Region             string `json:"region"`
+	Service            string `json:"service"`
+	NetworkBorderGroup string `json:"network_border_group"`
+}
+
 type AwsIpRanges struct {
-	SyncToken  string              `json:"syncToken"`
-	CreateDate string              `json:"createDate"`
-	Prefixes   []AwsIpRangesPrefix `json:"prefixes"`
+	SyncToken    string                `json:"syncToken"`
+	CreateDate   string                `json:"createDate"`
+	Prefixes     []AwsIpRangesPrefix   `json:"prefixes"`
+	Ipv6Prefixes []AwsIpv6RangesPrefix `json:"ipv6_prefixes"`
 }
@@ -2,7 +2,6 @@ package cloudfront
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,14 +11,43 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	awsnetwork "github.com/unfor19/columbus-app/internal/aws/network"
 	"github.com/unfor19/columbus-app/internal/aws/service/iam"
+	croute53 "github.com/unfor19/columbus-app/internal/aws/service/route53"
 	cs3 "github.com/unfor19/columbus-app/internal/aws/service/s3"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
 	"github.com/unfor19/columbus-app/pkg/traffic"
 )
 
 type AwsMapping struct {
+	Distribution      CloudFrontDistribution
 	CloudFrontOrigins []CloudFrontOrigin
-	TargetDomain      TargetAttributes
+	// Route explains which cache behavior and origin serve the request path
+	Route        *CloudFrontRoute `json:",omitempty"`
+	TargetDomain TargetAttributes
+}
+
+type CloudFrontDistribution struct {
+	Id         string
+	Arn        string
+	DomainName string
+	Status     string
+	Aliases    []string
+	// DefaultRootObject is the object served for requests to the root
+	DefaultRootObject string `json:",omitempty"`
+}
+
+func NewCloudFrontDistribution(distribution types.DistributionSummary) CloudFrontDistribution {
+	d := CloudFrontDistribution{
+		Id:         aws.ToString(distribution.Id),
+		Arn:        aws.ToString(distribution.ARN),
+		DomainName: aws.ToString(distribution.DomainName),
+		Status:     aws.ToString(distribution.Status),
+	}
+	if distribution.Aliases != nil {
+		d.Aliases = distribution.Aliases.Items
+	}
+	return d
 }
 
 type TargetAttributes struct {
@@ -27,14 +55,48 @@ type TargetAttributes struct {
 	RegisteredName  string
 	TargetIpAddress string
 	TargetService   string
-	UrlResponse     traffic.UrlResponse
-	EtagResponse    string
-	Route53Record   string
-	WafId           string
-	NsLookup        []string
+	// TargetIpRanges are the AWS prefixes of TargetIpAddress, most specific first
+	TargetIpRanges []awsnetwork.IpRangeMatch
+	UrlResponse    traffic.UrlResponse
+	EtagResponse   string
+	IndexFilePath  string
+	// RequestUrl is the explored URL, with the scheme it defaults to
+	RequestUrl string
+	// RequestPath is the path of the request URL, / when it has none
+	RequestPath   string
+	Route53Record string
+	// Route53Records are the record sets of DomainName in its public and
+	// private hosted zones
+	Route53Records []croute53.Route53Record `json:",omitempty"`
+	// Route53Zone is the hosted zone of DomainName, Route53NameServers its
+	// delegation set
+	Route53Zone        string   `json:",omitempty"`
+	Route53NameServers []string `json:",omitempty"`
+	// DistributionMatches are the distributions whose aliases match
+	// DomainName, exact matches first
+	DistributionMatches []DistributionMatch `json:",omitempty"`
+	// DnsTargets are the distributions the DNS records of DomainName point to
+	DnsTargets []DnsTarget `json:",omitempty"`
+	// Delegation is the trace of DomainName's delegation from the root
+	Delegation *cdns.Trace `json:",omitempty"`
+	WafId      string
+	// Resolution is how DomainName resolves to TargetIpAddress
+	Resolution cdns.Resolution
+	NsLookup   []string
+}
+
+// GetDefaultRootObject returns the default root object of the distribution,
+// which its summary does not have
+func GetDefaultRootObject(ctx context.Context, cfg aws.Config, distributionId string) (string, error) {
+	svc := cloudfront.NewFromConfig(cfg)
+	resp, err := svc.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{Id: aws.String(distributionId)})
+	if err != nil {
+		return "", fmt.Errorf("failed to get the configuration of distribution %s: %w", distributionId, err)
+	}
+	return aws.ToString(resp.DistributionConfig.DefaultRootObject), nil
 }
 
-func ListCloudfrontDistributions(cfg aws.Config) []types.DistributionSummary {
+func ListCloudfrontDistributions(ctx context.Context, cfg aws.Config) ([]types.DistributionSummary, error) {
 	svc := cloudfront.NewFromConfig(cfg)
 	isTruncated := true
 	nextMarker := aws.String("")
@@ -44,9 +106,9 @@ func ListCloudfrontDistributions(cfg aws.Config) []types.DistributionSummary {
 	}
 	var distributions []types.DistributionSummary
 	for isTruncated == true {
-		resp, err := svc.ListDistributions(context.TODO(), params)
+		resp, err := svc.ListDistributions(ctx, params)
 		if err != nil {
-			log.Fatalln("Failed to list for the first time:", err)
+			return nil, fmt.Errorf("failed to list CloudFront distributions: %w", err)
 		}
 
 		if *resp.DistributionList.IsTruncated {
@@ -59,44 +121,69 @@ func ListCloudfrontDistributions(cfg aws.Config) []types.DistributionSummary {
 		distributions = append(distributions, *&resp.DistributionList.Items...)
 	}
 	log.Println("Found", len(distributions), "distributions")
-	return distributions
+	return distributions, nil
 }
 
 type CloudFrontOrigin struct {
+	OriginId                   string
 	OriginType                 string
 	OriginName                 string
 	OriginUrl                  string
 	OriginPath                 string
+	OriginRegion               string `json:",omitempty"`
+	OriginIndexKey             string `json:",omitempty"`
 	OriginIndexETag            string
 	originBucketPolicy         string
 	OriginBucketPolicy         iam.PolicyDocument
+	OriginBucketPolicyError    string `json:",omitempty"`
 	OriginBucketPolicyIsPublic bool
 	OriginResourceExists       bool
 	OriginIsWebsite            bool
 	OriginUrlResponse          traffic.UrlResponse
+	OriginAccessIdentity       string               `json:",omitempty"`
+	OriginAccessControlId      string               `json:",omitempty"`
+	OriginAccessControl        *OriginAccessControl `json:",omitempty"`
+	OriginObjectKey            string               `json:",omitempty"`
+	OriginObjectAccess         []iam.Evaluation     `json:",omitempty"`
 }
 
-func (o CloudFrontOrigin) getOriginUrlResponse() http.Response {
-	var resp http.Response
-	if strings.ContainsAny(o.OriginType, "s3") {
+type OriginAccessControl struct {
+	Id              string
+	Name            string
+	OriginType      string
+	SigningBehavior string
+	SigningProtocol string
+}
+
+func GetOriginAccessControl(ctx context.Context, cfg aws.Config, id string) (*OriginAccessControl, error) {
+	svc := cloudfront.NewFromConfig(cfg)
+	params := cloudfront.GetOriginAccessControlInput{
+		Id: aws.String(id),
+	}
+	resp, err := svc.GetOriginAccessControl(ctx, &params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get origin access control %s: %w", id, err)
+	}
+	oac := &OriginAccessControl{Id: aws.ToString(resp.OriginAccessControl.Id)}
+	if c := resp.OriginAccessControl.OriginAccessControlConfig; c != nil {
+		oac.Name = aws.ToString(c.Name)
+		oac.OriginType = string(c.OriginAccessControlOriginType)
+		oac.SigningBehavior = string(c.SigningBehavior)
+		oac.SigningProtocol = string(c.SigningProtocol)
+	}
+	return oac, nil
+}
+
+func (o CloudFrontOrigin) getOriginUrlResponse(ctx context.Context) (http.Response, error) {
+	if strings.HasPrefix(o.OriginType, "s3") {
 		log.Println("s3", o.OriginUrl)
-		resp, err := http.Get("http://" + o.OriginUrl)
-		if err != nil {
-			log.Println(err)
-		}
-		return *resp
-	} else if strings.ContainsAny(o.OriginUrl, "execute-api") {
+		return traffic.GetRequestUrlResponse(ctx, "http://"+o.OriginUrl)
+	} else if o.OriginType == "apigw" {
 		log.Println("apigw", o.OriginUrl)
-		resp, err := http.Get("https://" + o.OriginUrl + "/" + o.OriginPath)
-		if err != nil {
-			log.Println(err)
-		}
-		return *resp
-	} else {
-		log.Println("unknown", o.OriginUrl)
-		log.Fatalln("Unknown origin type", o.OriginType)
+		return traffic.GetRequestUrlResponse(ctx, "https://"+o.OriginUrl+"/"+strings.TrimPrefix(o.OriginPath, "/"))
 	}
-	return resp
+	log.Println("unknown", o.OriginUrl)
+	return http.Response{}, fmt.Errorf("unknown origin type %q", o.OriginType)
 }
 
 func (o *CloudFrontOrigin) setOriginUrlResponse(resp http.Response) {
@@ -111,12 +198,12 @@ func (o *CloudFrontOrigin) setOriginUrlResponse(resp http.Response) {
 	o.OriginUrlResponse.StatusCode = resp.StatusCode
 }
 
-func (o *CloudFrontOrigin) setOriginPolicy(cfg aws.Config) {
+func (o *CloudFrontOrigin) setOriginPolicy(ctx context.Context, cfg aws.Config) {
 	svc := s3.NewFromConfig(cfg)
 	params := s3.GetBucketPolicyInput{
 		Bucket: &o.OriginName,
 	}
-	resp, err := svc.GetBucketPolicy(context.TODO(), &params)
+	resp, err := svc.GetBucketPolicy(ctx, &params)
 	if err != nil {
 		log.Println(err)
 		o.originBucketPolicy = "none"
@@ -126,13 +213,13 @@ func (o *CloudFrontOrigin) setOriginPolicy(cfg aws.Config) {
 	o.originBucketPolicy = *resp.Policy
 }
 
-func (o *CloudFrontOrigin) s3OriginIsPublic(cfg aws.Config) {
+func (o *CloudFrontOrigin) s3OriginIsPublic(ctx context.Context, cfg aws.Config) {
 	var isPublic bool
 	svc := s3.NewFromConfig(cfg)
 	params := s3.GetBucketPolicyStatusInput{
 		Bucket: &o.OriginName,
 	}
-	resp, err := svc.GetBucketPolicyStatus(context.TODO(), &params)
+	resp, err := svc.GetBucketPolicyStatus(ctx, &params)
 	if err != nil {
 		log.Println(err)
 		isPublic = false
@@ -143,74 +230,93 @@ func (o *CloudFrontOrigin) s3OriginIsPublic(cfg aws.Config) {
 	o.OriginBucketPolicyIsPublic = isPublic
 }
 
-func (o *CloudFrontOrigin) setIsBucketWebsite(cfg aws.Config) {
+func (o *CloudFrontOrigin) setIsBucketWebsite(ctx context.Context, cfg aws.Config) {
 	var isWebsite bool
 	svc := s3.NewFromConfig(cfg)
 	params := s3.GetBucketWebsiteInput{
 		Bucket: &o.OriginName,
 	}
-	resp, err := svc.GetBucketWebsite(context.TODO(), &params)
+	resp, err := svc.GetBucketWebsite(ctx, &params)
 	if err != nil {
+		// NoSuchWebsiteConfiguration means website hosting is disabled
+		if !strings.Contains(err.Error(), "NoSuchWebsiteConfiguration") {
+			log.Println(err)
+		}
 		isWebsite = false
 	} else {
-		isWebsite = resp.ResultMetadata.Has("IndexDocument")
+		isWebsite = resp.IndexDocument != nil || resp.RedirectAllRequestsTo != nil
 	}
 
 	o.OriginIsWebsite = isWebsite
 }
 
-func (o *CloudFrontOrigin) setIndexETag(cfg aws.Config, indexFilePath string) {
-	var eTag string
-	svc := s3.NewFromConfig(cfg)
-	params := s3.HeadObjectInput{
-		Bucket: &o.OriginName,
-		Key:    &indexFilePath,
-	}
-	resp, err := svc.HeadObject(context.TODO(), &params)
+func (o *CloudFrontOrigin) setIndexETag(ctx context.Context, cfg aws.Config) {
+	eTag, err := cs3.GetS3ObjectETag(ctx, cfg, o.OriginName, o.OriginIndexKey)
 	if err != nil {
 		log.Println(err)
 		eTag = ""
-	} else {
-		eTag = strings.ReplaceAll(aws.ToString(resp.ETag), "\"", "")
 	}
 
 	o.OriginIndexETag = eTag
 }
 
-func GetAwsCloudfrontOrigins(cfg aws.Config, distribution types.DistributionSummary, indexFilePath string) []CloudFrontOrigin {
+// BucketConfigFunc returns the configuration of the S3 clients of a bucket,
+// which may live in another account or region than the distribution.
+type BucketConfigFunc func(ctx context.Context, bucketName string, region string) aws.Config
+
+// setOriginRegion uses the region of the origin's endpoint, and discovers it
+// for the legacy global endpoint, falling back to the configured region.
+func (o *CloudFrontOrigin) setOriginRegion(ctx context.Context, cfg aws.Config, endpoint cs3.BucketEndpoint) {
+	o.OriginRegion = endpoint.Region
+	if o.OriginRegion != "" {
+		return
+	}
+	region, err := cs3.GetS3BucketRegion(ctx, cfg, endpoint.Bucket)
+	if err != nil {
+		log.Println(err)
+		region = cfg.Region
+	}
+	o.OriginRegion = region
+}
+
+func GetAwsCloudfrontOrigins(ctx context.Context, cfg aws.Config, bucketConfig BucketConfigFunc, distribution types.DistributionSummary, defaultRootObject string, indexFilePath string) []CloudFrontOrigin {
 	var origins []CloudFrontOrigin
 	for _, origin := range distribution.Origins.Items {
 		o := CloudFrontOrigin{}
+		o.OriginId = aws.ToString(origin.Id)
 		o.OriginPath = *origin.OriginPath
 		o.OriginUrl = *origin.DomainName
 		log.Println("Origin Domain Name", o.OriginUrl)
+		o.OriginAccessControlId = aws.ToString(origin.OriginAccessControlId)
 		if origin.S3OriginConfig != nil {
-			s3BucketName := strings.Split(*origin.DomainName, fmt.Sprintf(".s3.%s.", cfg.Region))[0]
-			log.Println("Target Origin is S3 Bucket:", s3BucketName)
-			o.OriginType = "s3-bucket"
-			o.OriginName = s3BucketName
-			if cs3.GetS3BucketExists(cfg, o.OriginName) {
-				o.setOriginPolicy(cfg)
-				o.setIndexETag(cfg, indexFilePath)
-				o.s3OriginIsPublic(cfg)
-				o.setIsBucketWebsite(cfg)
-				o.OriginResourceExists = true
+			o.OriginAccessIdentity = aws.ToString(origin.S3OriginConfig.OriginAccessIdentity)
+		}
+		endpoint, isS3 := cs3.ParseBucketEndpoint(o.OriginUrl)
+		if !isS3 && origin.S3OriginConfig != nil {
+			// Unknown endpoint format, the bucket's region is discovered
+			endpoint, isS3 = cs3.BucketEndpoint{Bucket: strings.Split(o.OriginUrl, ".s3")[0]}, true
+		}
+		if isS3 && (origin.S3OriginConfig != nil || endpoint.Website) {
+			o.OriginName = endpoint.Bucket
+			if endpoint.Website {
+				log.Println("Target Origin is S3 Website:", o.OriginUrl)
+				o.OriginType = "s3-website"
+			} else {
+				log.Println("Target Origin is S3 Bucket:", o.OriginName)
+				o.OriginType = "s3-bucket"
 			}
-			origins = append(origins, o)
-		} else if origin.CustomOriginConfig != nil && strings.Contains(aws.ToString(origin.DomainName), "s3-website") {
-			log.Println("Target Origin is S3 Website:", o.OriginUrl)
-			o.OriginType = "s3-website"
-			s3BucketName := strings.Split(o.OriginUrl, ".s3-website.")[0]
-			o.OriginName = s3BucketName
-			if cs3.GetS3BucketExists(cfg, o.OriginName) {
-				o.setOriginPolicy(cfg)
-				o.setIndexETag(cfg, indexFilePath)
-				o.s3OriginIsPublic(cfg)
-				o.setIsBucketWebsite(cfg)
+			o.OriginIndexKey = o.IndexKey(defaultRootObject, indexFilePath)
+			o.setOriginRegion(ctx, cfg, endpoint)
+			bucketCfg := bucketConfig(ctx, o.OriginName, o.OriginRegion)
+			if cs3.GetS3BucketExists(ctx, bucketCfg, o.OriginName) {
+				o.setOriginPolicy(ctx, bucketCfg)
+				o.setIndexETag(ctx, bucketCfg)
+				o.s3OriginIsPublic(ctx, bucketCfg)
+				o.setIsBucketWebsite(ctx, bucketCfg)
 				o.OriginResourceExists = true
 			}
 			origins = append(origins, o)
-		} else if strings.ContainsAny(aws.ToString(origin.DomainName), ".execute-api.") {
+		} else if strings.Contains(aws.ToString(origin.DomainName), ".execute-api.") {
 			log.Println("Target Origin is API Gateway type REST:", o.OriginUrl)
 			o.OriginType = "apigw"
 			apigwName := strings.Split(o.OriginUrl, ".execute-api.")[0]
@@ -221,46 +327,128 @@ func GetAwsCloudfrontOrigins(cfg aws.Config, distribution types.DistributionSumm
 	return origins
 }
 
-func GetTargetAwsCloudfrontDistribution(cfg aws.Config, distributions []types.DistributionSummary, domainName string, indexFilePath string) (types.DistributionSummary, []CloudFrontOrigin) {
+// DistributionMatch is a distribution that qualifies to serve the target
+// domain, by one of its aliases or its own domain name
+type DistributionMatch struct {
+	Id         string
+	DomainName string
+	// Alias is the alias or domain name of the distribution that matches
+	Alias    string
+	Wildcard bool
+	// Selected is the distribution that Columbus explores
+	Selected bool
+}
+
+// MatchesAlias reports whether domainName is served by the alias, either
+// equal to it or one label below a wildcard alias such as *.example.com
+func MatchesAlias(alias string, domainName string) bool {
+	alias = strings.ToLower(strings.TrimSuffix(alias, "."))
+	domainName = strings.ToLower(strings.TrimSuffix(domainName, "."))
+	if !strings.HasPrefix(alias, "*.") {
+		return alias == domainName
+	}
+	i := strings.IndexByte(domainName, '.')
+	return i > 0 && domainName[i+1:] == alias[2:]
+}
+
+// MatchDistributions returns the distributions that qualify to serve
+// domainName. CloudFront prefers an exact alias to a wildcard one, so the
+// exact matches come first.
+func MatchDistributions(distributions []types.DistributionSummary, domainName string) []DistributionMatch {
+	var exact, wildcard []DistributionMatch
 	for _, distribution := range distributions {
-		// Search by origins
-		origins := GetAwsCloudfrontOrigins(cfg, distribution, indexFilePath)
-		for _, o := range origins {
-			if strings.HasPrefix(o.OriginUrl, domainName) || strings.Contains(o.OriginUrl, ".execute-api.") {
-				log.Println("Found CloudFront Distribution,", *distribution.Id, *distribution.DomainName, "by Origin", o.OriginName)
-				return distribution, origins
+		m := DistributionMatch{Id: aws.ToString(distribution.Id), DomainName: aws.ToString(distribution.DomainName)}
+		names := []string{m.DomainName}
+		if distribution.Aliases != nil {
+			names = append(names, distribution.Aliases.Items...)
+		}
+		for _, name := range names {
+			// An exact alias replaces a wildcard one
+			if MatchesAlias(name, domainName) && (m.Alias == "" || m.Wildcard) {
+				m.Alias, m.Wildcard = name, strings.HasPrefix(name, "*.")
+			}
+		}
+		switch {
+		case m.Alias == "":
+		case m.Wildcard:
+			wildcard = append(wildcard, m)
+		default:
+			exact = append(exact, m)
+		}
+	}
+	return append(exact, wildcard...)
+}
+
+// Ambiguous reports whether several distributions qualify with the same
+// precedence, exact or wildcard, as the first one
+func Ambiguous(matches []DistributionMatch) bool {
+	return len(matches) > 1 && matches[0].Wildcard == matches[1].Wildcard
+}
+
+// SelectDistribution selects the distribution that serves domainName by its
+// aliases. When several distributions qualify, the one that domainName
+// resolves to through its CNAME chain is preferred. The Id of the selected
+// distribution is nil when none qualifies.
+func SelectDistribution(distributions []types.DistributionSummary, domainName string, resolution cdns.Resolution) (types.DistributionSummary, []DistributionMatch) {
+	matches := MatchDistributions(distributions, domainName)
+	if len(matches) == 0 {
+		return types.DistributionSummary{}, nil
+	}
+	selected := 0
+	if Ambiguous(matches) {
+		log.Println(len(matches), "CloudFront distributions match", domainName)
+	resolved:
+		for i, m := range matches {
+			if m.Wildcard != matches[0].Wildcard {
+				break
+			}
+			for _, c := range resolution.CnameChain {
+				if strings.EqualFold(strings.TrimSuffix(c.Value, "."), m.DomainName) {
+					selected = i
+					break resolved
+				}
 			}
 		}
 	}
+	matches[selected].Selected = true
 
-	return types.DistributionSummary{}, nil
+	for _, distribution := range distributions {
+		if aws.ToString(distribution.Id) == matches[selected].Id {
+			log.Println("Found CloudFront Distribution,", *distribution.Id, *distribution.DomainName, "by alias", matches[selected].Alias)
+			return distribution, matches
+		}
+	}
+	return types.DistributionSummary{}, matches
 }
 
-func SetAwsCloudFrontOrigins(cfg aws.Config, targetOrigins []CloudFrontOrigin) []CloudFrontOrigin {
+func SetAwsCloudFrontOrigins(ctx context.Context, cfg aws.Config, targetOrigins []CloudFrontOrigin) []CloudFrontOrigin {
 	for i, origin := range targetOrigins {
 		log.Println(i, "Origin Type:", origin.OriginType)
 		log.Println(i, "Origin Name:", origin.OriginName)
 		log.Println(i, "Origin Url:", origin.OriginUrl)
-		originUrlResponse := origin.getOriginUrlResponse()
-		targetOrigins[i].setOriginUrlResponse(originUrlResponse)
-		var bucketPolicy iam.PolicyDocument
-		policyBlob := []byte(targetOrigins[i].originBucketPolicy)
-		err := json.Unmarshal(policyBlob, &bucketPolicy)
+		originUrlResponse, err := origin.getOriginUrlResponse(ctx)
 		if err != nil {
 			log.Println(err)
+		} else {
+			targetOrigins[i].setOriginUrlResponse(originUrlResponse)
+		}
+		if policy := targetOrigins[i].originBucketPolicy; policy != "" && policy != "none" {
+			bucketPolicy, err := iam.ParsePolicyDocument(policy)
+			if err != nil {
+				log.Println(i, "Failed to parse bucket policy:", err)
+				targetOrigins[i].OriginBucketPolicyError = err.Error()
+			}
+			targetOrigins[i].OriginBucketPolicy = bucketPolicy
+		}
+		if origin.OriginAccessControlId != "" {
+			oac, err := GetOriginAccessControl(ctx, cfg, origin.OriginAccessControlId)
+			if err != nil {
+				log.Println(err)
+			} else {
+				log.Println(i, "Origin Access Control:", oac.Name, oac.SigningBehavior)
+				targetOrigins[i].OriginAccessControl = oac
+			}
 		}
-		targetOrigins[i].OriginBucketPolicy = bucketPolicy
-		// log.Println("Origin Response:")
-		// log.Println(i, "[", originUrlResponse.StatusCode, "]", originUrlResponse.Header)
-		// if origin.OriginResourceExists && strings.HasPrefix(origin.OriginType, "s3-") {
-		// 	if origin.OriginIndexETag != "" {
-		// 		log.Println(i, "Origin ETag:", origin.OriginIndexETag)
-		// 	}
-		// 	log.Println(i, "Is Resource Exists:", origin.OriginResourceExists)
-		// 	log.Println(i, "Is Website:", origin.OriginIsWebsite)
-		// 	log.Println(i, "Is Bucket Public:", origin.OriginBucketPolicyIsPublic)
-		// 	log.Println(i, "Origin Bucket Policy:", origin.originBucketPolicy)
-		// }
 	}
 	return targetOrigins
 }
@@ -0,0 +1,97 @@
+package cloudfront
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
+)
+
+func TestMatchesAlias(t *testing.T) {
+	tests := []struct {
+		alias      string
+		domainName string
+		expected   bool
+	}{
+		{"dev.example.com", "dev.example.com", true},
+		{"Dev.Example.com", "dev.example.com.", true},
+		{"dev.example.com", "app.dev.example.com", false},
+		{"*.example.com", "dev.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "app.dev.example.com", false},
+		{"*.example.com", "devexample.com", false},
+	}
+	for _, tt := range tests {
+		if MatchesAlias(tt.alias, tt.domainName) != tt.expected {
+			t.Error("Expected", tt.expected, "for", tt.alias, tt.domainName)
+		}
+	}
+}
+
+func distribution(id string, aliases ...string) types.DistributionSummary {
+	return types.DistributionSummary{
+		Id:         aws.String(id),
+		DomainName: aws.String(id + ".cloudfront.net"),
+		Aliases:    &types.Aliases{Items: aliases, Quantity: aws.Int32(int32(len(aliases)))},
+		Origins:    &types.Origins{Quantity: aws.Int32(0)},
+	}
+}
+
+func TestMatchDistributions(t *testing.T) {
+	distributions := []types.DistributionSummary{
+		distribution("dwildcard", "*.example.com"),
+		distribution("dapi", "api.example.com"),
+		distribution("dboth", "*.example.com", "dev.example.com"),
+		distribution("dother", "example.org"),
+	}
+	matches := MatchDistributions(distributions, "dev.example.com")
+	if len(matches) != 2 || matches[0].Id != "dboth" || matches[0].Wildcard || matches[1].Id != "dwildcard" || !matches[1].Wildcard {
+		t.Fatal("Expected the exact alias before the wildcard one, got", matches)
+	}
+	if Ambiguous(matches) {
+		t.Error("Expected an exact alias to take precedence over a wildcard one")
+	}
+
+	matches = MatchDistributions(distributions, "www.example.com")
+	if len(matches) != 2 || !Ambiguous(matches) {
+		t.Error("Expected two ambiguous wildcard matches, got", matches)
+	}
+
+	matches = MatchDistributions(distributions, "dother.cloudfront.net")
+	if len(matches) != 1 || matches[0].Alias != "dother.cloudfront.net" {
+		t.Error("Expected a match by the distribution's domain name, got", matches)
+	}
+
+	if matches := MatchDistributions(distributions, "example.com"); len(matches) != 0 {
+		t.Error("Expected no matches, got", matches)
+	}
+}
+
+func TestSelectDistribution(t *testing.T) {
+	distributions := []types.DistributionSummary{
+		distribution("done", "*.example.com"),
+		distribution("dtwo", "*.example.com"),
+	}
+	resolution := cdns.Resolution{CnameChain: []cdns.Record{{Name: "www.example.com.", Type: "CNAME", Value: "dtwo.cloudfront.net."}}}
+	distribution, matches := SelectDistribution(distributions, "www.example.com", resolution)
+	if aws.ToString(distribution.Id) != "dtwo" || !matches[1].Selected || matches[0].Selected {
+		t.Fatal("Expected the distribution of the CNAME chain, got", aws.ToString(distribution.Id), matches)
+	}
+	if distribution, matches := SelectDistribution(distributions, "example.org", resolution); distribution.Id != nil || len(matches) != 0 {
+		t.Fatal("Expected no distribution, got", aws.ToString(distribution.Id), matches)
+	}
+}
+
+func TestGetAwsCloudfrontOriginsApiGateway(t *testing.T) {
+	d := distribution("dapi")
+	d.Origins = &types.Origins{Items: []types.Origin{
+		{Id: aws.String("custom"), DomainName: aws.String("www.example.com"), OriginPath: aws.String("")},
+		{Id: aws.String("api"), DomainName: aws.String("abc123.execute-api.eu-west-1.amazonaws.com"), OriginPath: aws.String("/dev")},
+	}}
+	origins := GetAwsCloudfrontOrigins(context.Background(), aws.Config{}, nil, d, "", "index.html")
+	if len(origins) != 1 || origins[0].OriginType != "apigw" || origins[0].OriginName != "abc123" {
+		t.Fatal("Expected only the API Gateway origin, got", origins)
+	}
+}
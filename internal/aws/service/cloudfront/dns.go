@@ -0,0 +1,79 @@
+package cloudfront
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	croute53 "github.com/unfor19/columbus-app/internal/aws/service/route53"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
+)
+
+// Sources of a DnsTarget
+const (
+	DnsTargetRoute53Alias = "route53-alias"
+	DnsTargetRoute53Cname = "route53-cname"
+	DnsTargetResolution   = "resolution"
+)
+
+var distributionDomainPattern = regexp.MustCompile(`^d[a-z0-9]+\.cloudfront\.net$`)
+
+// IsDistributionDomain reports whether name is the default domain name of a
+// distribution, such as d111111abcdef8.cloudfront.net
+func IsDistributionDomain(name string) bool {
+	return distributionDomainPattern.MatchString(strings.ToLower(strings.TrimSuffix(name, ".")))
+}
+
+// DnsTarget is a distribution domain name that the DNS records of the target
+// domain point to
+type DnsTarget struct {
+	Source string
+	// Record is the record that points to DomainName, with its set
+	// identifier when it has one
+	Record     string
+	DomainName string
+	// DistributionId is empty when no explored distribution has DomainName
+	DistributionId string `json:",omitempty"`
+}
+
+// FindDnsTargets returns the distribution domain names of the Route53 alias
+// targets and CNAME values, and of the resolved CNAME chain, matched to the
+// distributions.
+func FindDnsTargets(records []croute53.Route53Record, resolution cdns.Resolution, distributions []types.DistributionSummary) []DnsTarget {
+	var targets []DnsTarget
+	seen := map[string]bool{}
+	add := func(source string, record string, domainName string) {
+		domainName = strings.ToLower(strings.TrimSuffix(domainName, "."))
+		if !IsDistributionDomain(domainName) || seen[source+record+domainName] {
+			return
+		}
+		seen[source+record+domainName] = true
+		target := DnsTarget{Source: source, Record: record, DomainName: domainName}
+		for _, d := range distributions {
+			if strings.EqualFold(aws.ToString(d.DomainName), domainName) {
+				target.DistributionId = aws.ToString(d.Id)
+			}
+		}
+		targets = append(targets, target)
+	}
+
+	for _, r := range records {
+		name := r.Name
+		if r.SetIdentifier != "" {
+			name += " (" + r.SetIdentifier + ")"
+		}
+		if r.AliasTarget != nil {
+			add(DnsTargetRoute53Alias, name, r.AliasTarget.DNSName)
+		}
+		if r.Type == "CNAME" {
+			for _, v := range r.Values {
+				add(DnsTargetRoute53Cname, name, v)
+			}
+		}
+	}
+	for _, c := range resolution.CnameChain {
+		add(DnsTargetResolution, c.Name, c.Value)
+	}
+	return targets
+}
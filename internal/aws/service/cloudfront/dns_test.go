@@ -0,0 +1,52 @@
+package cloudfront
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	croute53 "github.com/unfor19/columbus-app/internal/aws/service/route53"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
+)
+
+func TestFindDnsTargets(t *testing.T) {
+	records := []croute53.Route53Record{
+		{Name: "dev.example.com.", Type: "A", AliasTarget: &croute53.AliasTarget{DNSName: "d111.cloudfront.net."}},
+		{Name: "dev.example.com.", Type: "AAAA", AliasTarget: &croute53.AliasTarget{DNSName: "D111.cloudfront.net"}},
+		{Name: "dev.example.com.", Type: "A", SetIdentifier: "blue", AliasTarget: &croute53.AliasTarget{DNSName: "bucket.s3-website-eu-west-1.amazonaws.com."}},
+		{Name: "www.example.com.", Type: "CNAME", Values: []string{"d222.cloudfront.net"}},
+	}
+	resolution := cdns.Resolution{CnameChain: []cdns.Record{{Name: "dev.example.com.", Type: "CNAME", Value: "d111.cloudfront.net."}}}
+	distributions := []types.DistributionSummary{
+		{Id: aws.String("E111"), DomainName: aws.String("d111.cloudfront.net")},
+	}
+
+	targets := FindDnsTargets(records, resolution, distributions)
+	expected := []DnsTarget{
+		{Source: DnsTargetRoute53Alias, Record: "dev.example.com.", DomainName: "d111.cloudfront.net", DistributionId: "E111"},
+		{Source: DnsTargetRoute53Cname, Record: "www.example.com.", DomainName: "d222.cloudfront.net"},
+		{Source: DnsTargetResolution, Record: "dev.example.com.", DomainName: "d111.cloudfront.net", DistributionId: "E111"},
+	}
+	if len(targets) != len(expected) {
+		t.Fatal("Expected", expected, "got", targets)
+	}
+	for i := range expected {
+		if targets[i] != expected[i] {
+			t.Error("Expected", expected[i], "got", targets[i])
+		}
+	}
+}
+
+func TestIsDistributionDomain(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"d111111abcdef8.cloudfront.net":  true,
+		"d111111abcdef8.cloudfront.net.": true,
+		"cloudfront.net":                 false,
+		"example.com":                    false,
+		"d1.cloudfront.net.example.com":  false,
+	} {
+		if IsDistributionDomain(name) != expected {
+			t.Error("Expected", expected, "for", name)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package cloudfront
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+)
+
+const InvalidationStatusCompleted = "Completed"
+
+// CreateInvalidation invalidates paths in the distribution's edge caches and
+// returns the id of the created invalidation.
+func CreateInvalidation(ctx context.Context, cfg aws.Config, distributionId string, paths []string) (string, error) {
+	svc := cloudfront.NewFromConfig(cfg)
+	params := cloudfront.CreateInvalidationInput{
+		DistributionId: aws.String(distributionId),
+		InvalidationBatch: &types.InvalidationBatch{
+			CallerReference: aws.String("columbus-" + strconv.FormatInt(time.Now().UnixNano(), 10)),
+			Paths: &types.Paths{
+				Quantity: aws.Int32(int32(len(paths))),
+				Items:    paths,
+			},
+		},
+	}
+	resp, err := svc.CreateInvalidation(ctx, &params)
+	if err != nil {
+		return "", fmt.Errorf("failed to create invalidation for %s: %w", distributionId, err)
+	}
+	log.Println("Created invalidation", aws.ToString(resp.Invalidation.Id), "for distribution", distributionId, paths)
+	return aws.ToString(resp.Invalidation.Id), nil
+}
+
+func GetInvalidationStatus(ctx context.Context, cfg aws.Config, distributionId string, invalidationId string) (string, error) {
+	svc := cloudfront.NewFromConfig(cfg)
+	params := cloudfront.GetInvalidationInput{
+		DistributionId: aws.String(distributionId),
+		Id:             aws.String(invalidationId),
+	}
+	resp, err := svc.GetInvalidation(ctx, &params)
+	if err != nil {
+		return "", fmt.Errorf("failed to get invalidation %s: %w", invalidationId, err)
+	}
+	return aws.ToString(resp.Invalidation.Status), nil
+}
+
+// WaitForInvalidation polls the invalidation every interval until it is
+// completed or ctx is done.
+func WaitForInvalidation(ctx context.Context, cfg aws.Config, distributionId string, invalidationId string, interval time.Duration) error {
+	for {
+		status, err := GetInvalidationStatus(ctx, cfg, distributionId, invalidationId)
+		if err != nil {
+			return err
+		}
+		log.Println("Invalidation", invalidationId, "status:", status)
+		if status == InvalidationStatusCompleted {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
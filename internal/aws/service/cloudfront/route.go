@@ -0,0 +1,145 @@
+package cloudfront
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/unfor19/columbus-app/internal/aws/service/iam"
+	cs3 "github.com/unfor19/columbus-app/internal/aws/service/s3"
+)
+
+// DefaultPathPattern is the path pattern of the default cache behavior
+const DefaultPathPattern = "*"
+
+// CloudFrontRoute explains how the distribution serves a request path, the
+// cache behavior that matches it, the origin of the behavior, and the key of
+// the object in that origin.
+type CloudFrontRoute struct {
+	Path        string
+	PathPattern string
+	// Precedence is the position of the matching cache behavior, the
+	// default cache behavior is evaluated last
+	Precedence int
+	Default    bool
+	// Skipped are the path patterns evaluated before PathPattern
+	Skipped              []string `json:",omitempty"`
+	TargetOriginId       string
+	ViewerProtocolPolicy string
+	// OriginGroupId is set when TargetOriginId is an origin group, the route
+	// then uses the group's primary origin
+	OriginGroupId string `json:",omitempty"`
+	OriginId      string
+	OriginUrl     string
+	OriginPath    string
+	ObjectKey     string
+}
+
+// MatchesPathPattern reports whether the path pattern of a cache behavior
+// matches path. Patterns are case sensitive, * matches any characters
+// including /, ? matches a single character, and the leading / is optional.
+func MatchesPathPattern(pattern string, path string) bool {
+	return iam.MatchWildcard("/"+strings.TrimPrefix(pattern, "/"), "/"+strings.TrimPrefix(path, "/"))
+}
+
+// OriginObjectKey returns the key of the object behind path in an origin.
+// CloudFront requests defaultRootObject for the root of the distribution,
+// and S3 website endpoints serve directories with their indexDocument, which
+// is empty for the other origins.
+func OriginObjectKey(path string, originPath string, defaultRootObject string, indexDocument string) string {
+	if path == "" {
+		path = "/"
+	}
+	switch {
+	case path == "/" && defaultRootObject != "":
+		path += strings.TrimPrefix(defaultRootObject, "/")
+	case strings.HasSuffix(path, "/") && indexDocument != "":
+		path += strings.TrimPrefix(indexDocument, "/")
+	}
+	return strings.TrimPrefix(strings.TrimSuffix(originPath, "/")+path, "/")
+}
+
+// ObjectKey returns the key of the object behind path in the S3 origin, S3
+// website origins serve directories with the index file.
+func (o CloudFrontOrigin) ObjectKey(path string, defaultRootObject string, indexFilePath string) string {
+	if o.OriginType != "s3-website" {
+		indexFilePath = ""
+	}
+	return OriginObjectKey(path, o.OriginPath, defaultRootObject, indexFilePath)
+}
+
+// IndexKey returns the key of the index object in the S3 origin, the object
+// served for the root, or the index file when the root serves no object.
+func (o CloudFrontOrigin) IndexKey(defaultRootObject string, indexFilePath string) string {
+	key := o.ObjectKey("/", defaultRootObject, indexFilePath)
+	if key == "" || strings.HasSuffix(key, "/") {
+		key = o.ObjectKey("/"+strings.TrimPrefix(indexFilePath, "/"), "", indexFilePath)
+	}
+	return key
+}
+
+// IsWebsiteOrigin reports whether the origin is an S3 website endpoint
+func IsWebsiteOrigin(originUrl string) bool {
+	endpoint, ok := cs3.ParseBucketEndpoint(originUrl)
+	return ok && endpoint.Website
+}
+
+// GetRoute evaluates the ordered cache behaviors of the distribution, then
+// its default cache behavior, to find the origin that serves path.
+// indexDocument is the index document of the S3 website origins.
+func GetRoute(distribution types.DistributionSummary, path string, defaultRootObject string, indexDocument string) CloudFrontRoute {
+	if path == "" {
+		path = "/"
+	}
+	route := CloudFrontRoute{Path: path}
+	matched := false
+	if distribution.CacheBehaviors != nil {
+		for i, behavior := range distribution.CacheBehaviors.Items {
+			pattern := aws.ToString(behavior.PathPattern)
+			if !MatchesPathPattern(pattern, path) {
+				route.Skipped = append(route.Skipped, pattern)
+				continue
+			}
+			route.PathPattern = pattern
+			route.Precedence = i
+			route.TargetOriginId = aws.ToString(behavior.TargetOriginId)
+			route.ViewerProtocolPolicy = string(behavior.ViewerProtocolPolicy)
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		route.PathPattern = DefaultPathPattern
+		route.Precedence = len(route.Skipped)
+		route.Default = true
+		if behavior := distribution.DefaultCacheBehavior; behavior != nil {
+			route.TargetOriginId = aws.ToString(behavior.TargetOriginId)
+			route.ViewerProtocolPolicy = string(behavior.ViewerProtocolPolicy)
+		}
+	}
+
+	route.OriginId = route.TargetOriginId
+	if distribution.OriginGroups != nil {
+		for _, group := range distribution.OriginGroups.Items {
+			if aws.ToString(group.Id) != route.TargetOriginId || group.Members == nil || len(group.Members.Items) == 0 {
+				continue
+			}
+			route.OriginGroupId = route.TargetOriginId
+			route.OriginId = aws.ToString(group.Members.Items[0].OriginId)
+		}
+	}
+	if distribution.Origins != nil {
+		for _, origin := range distribution.Origins.Items {
+			if aws.ToString(origin.Id) != route.OriginId {
+				continue
+			}
+			route.OriginUrl = aws.ToString(origin.DomainName)
+			route.OriginPath = aws.ToString(origin.OriginPath)
+		}
+	}
+	if !IsWebsiteOrigin(route.OriginUrl) {
+		indexDocument = ""
+	}
+	route.ObjectKey = OriginObjectKey(path, route.OriginPath, defaultRootObject, indexDocument)
+	return route
+}
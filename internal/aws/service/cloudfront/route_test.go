@@ -0,0 +1,136 @@
+package cloudfront
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+)
+
+func TestMatchesPathPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"*", "/", true},
+		{"*", "/app.js", true},
+		{"/api/*", "/api/users/1", true},
+		{"api/*", "/api/users", true},
+		{"/api/*", "/api", false},
+		{"/api/*", "/API/users", false},
+		{"*.jpg", "/images/cat.jpg", true},
+		{"*.jpg", "/images/cat.jpeg", false},
+		{"/images/*.jpg", "/images/2021/cat.jpg", true},
+		{"/v?/*", "/v2/users", true},
+		{"/v?/*", "/v10/users", false},
+		{"/index.html", "/index.html", true},
+		{"/index.html", "/index.htm", false},
+	}
+	for _, tt := range tests {
+		if MatchesPathPattern(tt.pattern, tt.path) != tt.expected {
+			t.Error("Expected", tt.expected, "for", tt.pattern, tt.path)
+		}
+	}
+}
+
+func TestCloudFrontOriginObjectKey(t *testing.T) {
+	tests := []struct {
+		path              string
+		originType        string
+		originPath        string
+		defaultRootObject string
+		key               string
+	}{
+		{"/", "s3-bucket", "", "index.html", "index.html"},
+		{"/", "s3-bucket", "", "", ""},
+		{"/docs/", "s3-bucket", "", "index.html", "docs/"},
+		{"/", "s3-website", "", "", "index.html"},
+		{"/docs/", "s3-website", "", "", "docs/index.html"},
+		{"/app.js", "s3-bucket", "", "index.html", "app.js"},
+		{"/app.js", "s3-bucket", "/production", "index.html", "production/app.js"},
+		{"/", "s3-bucket", "/production/", "index.html", "production/index.html"},
+	}
+	for _, tt := range tests {
+		o := CloudFrontOrigin{OriginType: tt.originType, OriginPath: tt.originPath}
+		if key := o.ObjectKey(tt.path, tt.defaultRootObject, "index.html"); key != tt.key {
+			t.Error(tt.path, tt.originType, tt.originPath, tt.defaultRootObject, "expected", tt.key, "got", key)
+		}
+	}
+}
+
+func TestCloudFrontOriginIndexKey(t *testing.T) {
+	tests := []struct {
+		originType        string
+		originPath        string
+		defaultRootObject string
+		key               string
+	}{
+		{"s3-bucket", "/production", "home.html", "production/home.html"},
+		// The root serves no object without a default root object
+		{"s3-bucket", "/production", "", "production/index.html"},
+		{"s3-bucket", "", "", "index.html"},
+		{"s3-website", "/production/", "", "production/index.html"},
+	}
+	for _, tt := range tests {
+		o := CloudFrontOrigin{OriginType: tt.originType, OriginPath: tt.originPath}
+		if key := o.IndexKey(tt.defaultRootObject, "index.html"); key != tt.key {
+			t.Error(tt.originType, tt.originPath, tt.defaultRootObject, "expected", tt.key, "got", key)
+		}
+	}
+}
+
+func TestGetRoute(t *testing.T) {
+	distribution := types.DistributionSummary{
+		DefaultCacheBehavior: &types.DefaultCacheBehavior{TargetOriginId: aws.String("site"), ViewerProtocolPolicy: types.ViewerProtocolPolicyRedirectToHttps},
+		CacheBehaviors: &types.CacheBehaviors{Items: []types.CacheBehavior{
+			{PathPattern: aws.String("/api/*"), TargetOriginId: aws.String("api"), ViewerProtocolPolicy: types.ViewerProtocolPolicyHttpsOnly},
+			{PathPattern: aws.String("*.jpg"), TargetOriginId: aws.String("images")},
+			{PathPattern: aws.String("/blog/*"), TargetOriginId: aws.String("blog")},
+		}},
+		OriginGroups: &types.OriginGroups{Items: []types.OriginGroup{
+			{Id: aws.String("images"), Members: &types.OriginGroupMembers{Items: []types.OriginGroupMember{{OriginId: aws.String("images-primary")}, {OriginId: aws.String("images-secondary")}}}},
+		}},
+		Origins: &types.Origins{Items: []types.Origin{
+			{Id: aws.String("site"), DomainName: aws.String("site.s3.eu-west-1.amazonaws.com"), OriginPath: aws.String("/production")},
+			{Id: aws.String("api"), DomainName: aws.String("abc.execute-api.eu-west-1.amazonaws.com"), OriginPath: aws.String("/dev")},
+			{Id: aws.String("images-primary"), DomainName: aws.String("images.s3.eu-west-1.amazonaws.com"), OriginPath: aws.String("")},
+			{Id: aws.String("blog"), DomainName: aws.String("blog.s3-website-eu-west-1.amazonaws.com"), OriginPath: aws.String("")},
+		}},
+	}
+	tests := []struct {
+		path     string
+		expected CloudFrontRoute
+	}{
+		{"/api/users", CloudFrontRoute{
+			Path: "/api/users", PathPattern: "/api/*", TargetOriginId: "api", ViewerProtocolPolicy: "https-only",
+			OriginId: "api", OriginUrl: "abc.execute-api.eu-west-1.amazonaws.com", OriginPath: "/dev", ObjectKey: "dev/api/users",
+		}},
+		{"/images/cat.jpg", CloudFrontRoute{
+			Path: "/images/cat.jpg", PathPattern: "*.jpg", Precedence: 1, Skipped: []string{"/api/*"}, TargetOriginId: "images",
+			OriginGroupId: "images", OriginId: "images-primary", OriginUrl: "images.s3.eu-west-1.amazonaws.com", ObjectKey: "images/cat.jpg",
+		}},
+		// The REST endpoint serves the default root object for the root only
+		{"/", CloudFrontRoute{
+			Path: "/", PathPattern: DefaultPathPattern, Precedence: 3, Default: true, Skipped: []string{"/api/*", "*.jpg", "/blog/*"},
+			TargetOriginId: "site", ViewerProtocolPolicy: "redirect-to-https",
+			OriginId: "site", OriginUrl: "site.s3.eu-west-1.amazonaws.com", OriginPath: "/production", ObjectKey: "production/root.html",
+		}},
+		{"/docs/", CloudFrontRoute{
+			Path: "/docs/", PathPattern: DefaultPathPattern, Precedence: 3, Default: true, Skipped: []string{"/api/*", "*.jpg", "/blog/*"},
+			TargetOriginId: "site", ViewerProtocolPolicy: "redirect-to-https",
+			OriginId: "site", OriginUrl: "site.s3.eu-west-1.amazonaws.com", OriginPath: "/production", ObjectKey: "production/docs/",
+		}},
+		// The website endpoint serves the index document of directories
+		{"/blog/", CloudFrontRoute{
+			Path: "/blog/", PathPattern: "/blog/*", Precedence: 2, Skipped: []string{"/api/*", "*.jpg"}, TargetOriginId: "blog",
+			OriginId: "blog", OriginUrl: "blog.s3-website-eu-west-1.amazonaws.com", ObjectKey: "blog/index.html",
+		}},
+	}
+	for _, tt := range tests {
+		if route := GetRoute(distribution, tt.path, "root.html", "index.html"); !reflect.DeepEqual(route, tt.expected) {
+			t.Errorf("Expected %+v\ngot %+v", tt.expected, route)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package iam
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Decision string
+
+const (
+	DecisionAllow        Decision = "Allow"
+	DecisionExplicitDeny Decision = "ExplicitDeny"
+	DecisionImplicitDeny Decision = "ImplicitDeny"
+)
+
+const (
+	PrincipalTypeAnonymous     = "Anonymous"
+	PrincipalTypeAWS           = "AWS"
+	PrincipalTypeService       = "Service"
+	PrincipalTypeFederated     = "Federated"
+	PrincipalTypeCanonicalUser = "CanonicalUser"
+)
+
+// RequestPrincipal is the caller of a request, Id is an ARN for AWS
+// principals, or the service name for service principals.
+type RequestPrincipal struct {
+	Type string
+	Id   string
+}
+
+var AnonymousPrincipal = RequestPrincipal{Type: PrincipalTypeAnonymous}
+
+func AwsPrincipal(arn string) RequestPrincipal {
+	return RequestPrincipal{Type: PrincipalTypeAWS, Id: arn}
+}
+
+func ServicePrincipal(service string) RequestPrincipal {
+	return RequestPrincipal{Type: PrincipalTypeService, Id: service}
+}
+
+func (p RequestPrincipal) String() string {
+	if p.Type == PrincipalTypeAnonymous {
+		return "anonymous"
+	}
+	return p.Id
+}
+
+// Request is the request context a policy is evaluated against. Context
+// holds the condition keys, such as aws:SourceArn, keys are case insensitive.
+type Request struct {
+	Principal RequestPrincipal
+	Action    string
+	Resource  string
+	Context   map[string]Value
+}
+
+type Evaluation struct {
+	Principal  string
+	Action     string
+	Resource   string
+	Decision   Decision
+	Statements []string `json:",omitempty"`
+}
+
+// Evaluate applies the allow and deny logic of AWS to a single resource based
+// policy, an explicit deny overrides any allow, and no matching statement
+// results in an implicit deny. Identity based policies, SCPs and permission
+// boundaries are not taken into account, for a principal of another account
+// an Allow means the bucket side allows it.
+func (p PolicyDocument) Evaluate(r Request) Evaluation {
+	e := Evaluation{
+		Principal: r.Principal.String(),
+		Action:    r.Action,
+		Resource:  r.Resource,
+		Decision:  DecisionImplicitDeny,
+	}
+	var allows, denies []string
+	for i, s := range p.Statement {
+		if !s.applies(r) {
+			continue
+		}
+		id := s.Sid
+		if id == "" {
+			id = "Statement" + strconv.Itoa(i)
+		}
+		if s.Effect == "Deny" {
+			denies = append(denies, id)
+		} else if s.Effect == "Allow" {
+			allows = append(allows, id)
+		}
+	}
+	if len(denies) > 0 {
+		e.Decision = DecisionExplicitDeny
+		e.Statements = denies
+	} else if len(allows) > 0 {
+		e.Decision = DecisionAllow
+		e.Statements = allows
+	}
+	return e
+}
+
+func (s StatementEntry) applies(r Request) bool {
+	if s.Principal != nil && !s.Principal.matches(r.Principal) {
+		return false
+	}
+	if s.NotPrincipal != nil && s.NotPrincipal.matches(r.Principal) {
+		return false
+	}
+	if len(s.NotAction) > 0 {
+		if s.NotAction.contains(r.Action, false) {
+			return false
+		}
+	} else if !s.Action.contains(r.Action, false) {
+		return false
+	}
+	if len(s.NotResource) > 0 {
+		if substitute(s.NotResource, r.Context).contains(r.Resource, true) {
+			return false
+		}
+	} else if len(s.Resource) > 0 && !substitute(s.Resource, r.Context).contains(r.Resource, true) {
+		return false
+	}
+	return s.Condition.matches(r.Context)
+}
+
+func accountOf(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 5 {
+		return ""
+	}
+	return parts[4]
+}
+
+func (p Principal) matches(r RequestPrincipal) bool {
+	if p.All {
+		return true
+	}
+	switch r.Type {
+	case PrincipalTypeAnonymous:
+		// "AWS": "*" is the same as "*"
+		for _, v := range p.AWS {
+			if v == "*" {
+				return true
+			}
+		}
+		return false
+	case PrincipalTypeAWS:
+		for _, v := range p.AWS {
+			if v == "*" || v == r.Id {
+				return true
+			}
+			// An account id or the account's root grants every principal of the account
+			account := accountOf(r.Id)
+			if account != "" && (v == account || v == "arn:aws:iam::"+account+":root") {
+				return true
+			}
+		}
+		return false
+	case PrincipalTypeService:
+		return p.Service.contains(r.Id, false)
+	case PrincipalTypeFederated:
+		return p.Federated.contains(r.Id, true)
+	case PrincipalTypeCanonicalUser:
+		return p.CanonicalUser.contains(r.Id, true)
+	}
+	return false
+}
+
+// substitute replaces policy variables, such as ${aws:username}, with their
+// values in the request context.
+func substitute(v Value, context map[string]Value) Value {
+	var substituted Value
+	for _, s := range v {
+		if !strings.Contains(s, "${") {
+			substituted = append(substituted, s)
+			continue
+		}
+		var b strings.Builder
+		for {
+			start := strings.Index(s, "${")
+			if start < 0 {
+				break
+			}
+			end := strings.Index(s[start:], "}")
+			if end < 0 {
+				break
+			}
+			b.WriteString(s[:start])
+			name := s[start+2 : start+end]
+			switch name {
+			case "*", "?", "$":
+				b.WriteString(name)
+			default:
+				if values := lookup(context, name); len(values) > 0 {
+					b.WriteString(values[0])
+				} else {
+					b.WriteString(s[start : start+end+1])
+				}
+			}
+			s = s[start+end+1:]
+		}
+		b.WriteString(s)
+		substituted = append(substituted, b.String())
+	}
+	return substituted
+}
+
+func lookup(context map[string]Value, key string) Value {
+	for k, v := range context {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+func (c Condition) matches(context map[string]Value) bool {
+	for operator, keys := range c {
+		for key, values := range keys {
+			if !evaluateCondition(operator, lookup(context, key), substitute(values, context)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// evaluateCondition evaluates a single condition key, supporting the
+// ForAnyValue: and ForAllValues: set operators and the IfExists suffix.
+func evaluateCondition(operator string, requestValues Value, policyValues Value) bool {
+	forAll := false
+	if strings.HasPrefix(operator, "ForAllValues:") {
+		forAll = true
+		operator = strings.TrimPrefix(operator, "ForAllValues:")
+	} else {
+		operator = strings.TrimPrefix(operator, "ForAnyValue:")
+	}
+	if operator == "Null" {
+		isNull := len(requestValues) == 0
+		for _, v := range policyValues {
+			if strings.EqualFold(v, strconv.FormatBool(isNull)) {
+				return true
+			}
+		}
+		return false
+	}
+	ifExists := strings.HasSuffix(operator, "IfExists")
+	operator = strings.TrimSuffix(operator, "IfExists")
+	negated := negatedOperators[operator]
+	if negated {
+		operator = strings.Replace(operator, "Not", "", 1)
+	}
+	compare, ok := comparators[operator]
+	if !ok {
+		// Unknown operators never match, like in AWS
+		return false
+	}
+
+	if len(requestValues) == 0 {
+		return ifExists || negated || forAll
+	}
+	matchesAny := func(requestValue string) bool {
+		for _, policyValue := range policyValues {
+			if compare(requestValue, policyValue) {
+				return true
+			}
+		}
+		return false
+	}
+	if forAll {
+		for _, v := range requestValues {
+			if matchesAny(v) == negated {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range requestValues {
+		if matchesAny(v) {
+			return !negated
+		}
+	}
+	return negated
+}
+
+var negatedOperators = map[string]bool{
+	"StringNotEquals":           true,
+	"StringNotEqualsIgnoreCase": true,
+	"StringNotLike":             true,
+	"NumericNotEquals":          true,
+	"DateNotEquals":             true,
+	"NotIpAddress":              true,
+	"ArnNotEquals":              true,
+	"ArnNotLike":                true,
+}
+
+type comparator func(requestValue string, policyValue string) bool
+
+// comparators are keyed by the positive form of each operator, the negated
+// operators remove "Not" from their name.
+var comparators = map[string]comparator{
+	"StringEquals":             func(r, p string) bool { return r == p },
+	"StringEqualsIgnoreCase":   strings.EqualFold,
+	"StringLike":               func(r, p string) bool { return MatchWildcard(p, r) },
+	"NumericEquals":            numeric(func(r, p float64) bool { return r == p }),
+	"NumericLessThan":          numeric(func(r, p float64) bool { return r < p }),
+	"NumericLessThanEquals":    numeric(func(r, p float64) bool { return r <= p }),
+	"NumericGreaterThan":       numeric(func(r, p float64) bool { return r > p }),
+	"NumericGreaterThanEquals": numeric(func(r, p float64) bool { return r >= p }),
+	"DateEquals":               date(func(r, p time.Time) bool { return r.Equal(p) }),
+	"DateLessThan":             date(func(r, p time.Time) bool { return r.Before(p) }),
+	"DateLessThanEquals":       date(func(r, p time.Time) bool { return !r.After(p) }),
+	"DateGreaterThan":          date(func(r, p time.Time) bool { return r.After(p) }),
+	"DateGreaterThanEquals":    date(func(r, p time.Time) bool { return !r.Before(p) }),
+	"Bool":                     strings.EqualFold,
+	"BinaryEquals":             func(r, p string) bool { return r == p },
+	"IpAddress":                ipAddress,
+	"ArnEquals":                arnLike,
+	"ArnLike":                  arnLike,
+}
+
+func numeric(compare func(r, p float64) bool) comparator {
+	return func(r, p string) bool {
+		rv, err := strconv.ParseFloat(r, 64)
+		if err != nil {
+			return false
+		}
+		pv, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return false
+		}
+		return compare(rv, pv)
+	}
+}
+
+func parseDate(s string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true
+	}
+	if epoch, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(epoch, 0), true
+	}
+	return time.Time{}, false
+}
+
+func date(compare func(r, p time.Time) bool) comparator {
+	return func(r, p string) bool {
+		rv, ok := parseDate(r)
+		if !ok {
+			return false
+		}
+		pv, ok := parseDate(p)
+		if !ok {
+			return false
+		}
+		return compare(rv, pv)
+	}
+}
+
+func ipAddress(r, p string) bool {
+	ip := net.ParseIP(r)
+	if ip == nil {
+		return false
+	}
+	if !strings.Contains(p, "/") {
+		return ip.Equal(net.ParseIP(p))
+	}
+	_, cidr, err := net.ParseCIDR(p)
+	return err == nil && cidr.Contains(ip)
+}
+
+// arnLike compares each of the six colon separated ARN components, wildcards
+// do not cross component boundaries.
+func arnLike(r, p string) bool {
+	rParts := strings.SplitN(r, ":", 6)
+	pParts := strings.SplitN(p, ":", 6)
+	if len(rParts) != 6 || len(pParts) != 6 {
+		return MatchWildcard(p, r)
+	}
+	for i := range rParts {
+		if !MatchWildcard(pParts[i], rParts[i]) {
+			return false
+		}
+	}
+	return true
+}
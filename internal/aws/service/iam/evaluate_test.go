@@ -0,0 +1,145 @@
+package iam
+
+import (
+	"testing"
+)
+
+const bucketPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "AllowCloudFront",
+      "Effect": "Allow",
+      "Principal": {"Service": "cloudfront.amazonaws.com"},
+      "Action": "s3:GetObject",
+      "Resource": "arn:aws:s3:::bucket/*",
+      "Condition": {"StringEquals": {"AWS:SourceArn": "arn:aws:cloudfront::111122223333:distribution/E123"}}
+    },
+    {
+      "Sid": "AllowOai",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity EABC"},
+      "Action": "s3:GetObject",
+      "Resource": "arn:aws:s3:::bucket/*"
+    },
+    {
+      "Sid": "PublicAssets",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": "s3:Get*",
+      "Resource": "arn:aws:s3:::bucket/assets/*"
+    },
+    {
+      "Sid": "DeployRole",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::111122223333:role/deploy"},
+      "Action": ["s3:PutObject", "s3:GetObject"],
+      "Resource": "arn:aws:s3:::bucket/*"
+    },
+    {
+      "Sid": "Partner",
+      "Effect": "Allow",
+      "Principal": {"AWS": "444455556666"},
+      "Action": "s3:GetObject",
+      "Resource": "arn:aws:s3:::bucket/shared/${aws:username}/*"
+    },
+    {
+      "Sid": "DenyInsecureTransport",
+      "Effect": "Deny",
+      "Principal": "*",
+      "Action": "s3:*",
+      "Resource": "arn:aws:s3:::bucket/*",
+      "Condition": {"Bool": {"aws:SecureTransport": "false"}}
+    },
+    {
+      "Sid": "DenyOutsideOffice",
+      "Effect": "Deny",
+      "Principal": {"AWS": "arn:aws:iam::111122223333:role/deploy"},
+      "NotAction": "s3:GetObject",
+      "Resource": "arn:aws:s3:::bucket/*",
+      "Condition": {"NotIpAddress": {"aws:SourceIp": ["203.0.113.0/24"]}}
+    }
+  ]
+}`
+
+func TestEvaluate(t *testing.T) {
+	policy, err := ParsePolicyDocument(bucketPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secure := Value{"true"}
+	tests := []struct {
+		name     string
+		request  Request
+		decision Decision
+	}{
+		{"cloudfront oac", Request{ServicePrincipal("cloudfront.amazonaws.com"), "s3:GetObject", "arn:aws:s3:::bucket/index.html",
+			map[string]Value{"aws:SourceArn": {"arn:aws:cloudfront::111122223333:distribution/E123"}, "aws:SecureTransport": secure}}, DecisionAllow},
+		{"cloudfront other distribution", Request{ServicePrincipal("cloudfront.amazonaws.com"), "s3:GetObject", "arn:aws:s3:::bucket/index.html",
+			map[string]Value{"aws:SourceArn": {"arn:aws:cloudfront::111122223333:distribution/EOTHER"}, "aws:SecureTransport": secure}}, DecisionImplicitDeny},
+		{"oai", Request{AwsPrincipal("arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity EABC"), "s3:GetObject", "arn:aws:s3:::bucket/index.html",
+			map[string]Value{"aws:SecureTransport": secure}}, DecisionAllow},
+		{"anonymous index", Request{AnonymousPrincipal, "s3:GetObject", "arn:aws:s3:::bucket/index.html",
+			map[string]Value{"aws:SecureTransport": secure}}, DecisionImplicitDeny},
+		{"anonymous assets", Request{AnonymousPrincipal, "s3:getobject", "arn:aws:s3:::bucket/assets/app.js",
+			map[string]Value{"aws:SecureTransport": secure}}, DecisionAllow},
+		{"anonymous insecure", Request{AnonymousPrincipal, "s3:GetObject", "arn:aws:s3:::bucket/assets/app.js",
+			map[string]Value{"aws:SecureTransport": {"false"}}}, DecisionExplicitDeny},
+		{"deploy role get", Request{AwsPrincipal("arn:aws:iam::111122223333:role/deploy"), "s3:GetObject", "arn:aws:s3:::bucket/index.html",
+			map[string]Value{"aws:SecureTransport": secure, "aws:SourceIp": {"198.51.100.1"}}}, DecisionAllow},
+		{"deploy role put outside office", Request{AwsPrincipal("arn:aws:iam::111122223333:role/deploy"), "s3:PutObject", "arn:aws:s3:::bucket/index.html",
+			map[string]Value{"aws:SecureTransport": secure, "aws:SourceIp": {"198.51.100.1"}}}, DecisionExplicitDeny},
+		{"deploy role put in office", Request{AwsPrincipal("arn:aws:iam::111122223333:role/deploy"), "s3:PutObject", "arn:aws:s3:::bucket/index.html",
+			map[string]Value{"aws:SecureTransport": secure, "aws:SourceIp": {"203.0.113.7"}}}, DecisionAllow},
+		{"partner own prefix", Request{AwsPrincipal("arn:aws:iam::444455556666:user/alice"), "s3:GetObject", "arn:aws:s3:::bucket/shared/alice/report.csv",
+			map[string]Value{"aws:SecureTransport": secure, "aws:username": {"alice"}}}, DecisionAllow},
+		{"partner other prefix", Request{AwsPrincipal("arn:aws:iam::444455556666:user/alice"), "s3:GetObject", "arn:aws:s3:::bucket/shared/bob/report.csv",
+			map[string]Value{"aws:SecureTransport": secure, "aws:username": {"alice"}}}, DecisionImplicitDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := policy.Evaluate(tt.request)
+			if e.Decision != tt.decision {
+				t.Fatal("Expected", tt.decision, "got", e.Decision, e.Statements)
+			}
+		})
+	}
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	tests := []struct {
+		operator string
+		request  Value
+		policy   Value
+		result   bool
+	}{
+		{"StringEquals", Value{"a"}, Value{"a", "b"}, true},
+		{"StringEquals", nil, Value{"a"}, false},
+		{"StringEqualsIfExists", nil, Value{"a"}, true},
+		{"StringNotEquals", Value{"c"}, Value{"a", "b"}, true},
+		{"StringNotEquals", nil, Value{"a"}, true},
+		{"StringEqualsIgnoreCase", Value{"A"}, Value{"a"}, true},
+		{"StringLike", Value{"home/alice/x"}, Value{"home/*/x"}, true},
+		{"StringNotLike", Value{"home/alice/x"}, Value{"home/*/x"}, false},
+		{"NumericLessThan", Value{"1.1"}, Value{"1.2"}, true},
+		{"NumericGreaterThanEquals", Value{"5"}, Value{"10"}, false},
+		{"DateLessThan", Value{"2020-01-01T00:00:00Z"}, Value{"2021-01-01T00:00:00Z"}, true},
+		{"Bool", Value{"TRUE"}, Value{"true"}, true},
+		{"IpAddress", Value{"10.0.0.1"}, Value{"10.0.0.0/8"}, true},
+		{"NotIpAddress", Value{"10.0.0.1"}, Value{"10.0.0.0/8"}, false},
+		{"ArnLike", Value{"arn:aws:iam::111122223333:role/deploy"}, Value{"arn:aws:iam::*:role/*"}, true},
+		{"ArnNotLike", Value{"arn:aws:iam::111122223333:role/deploy"}, Value{"arn:aws:iam::*:user/*"}, true},
+		{"Null", nil, Value{"true"}, true},
+		{"Null", Value{"x"}, Value{"true"}, false},
+		{"ForAnyValue:StringEquals", Value{"a", "z"}, Value{"a"}, true},
+		{"ForAllValues:StringEquals", Value{"a", "z"}, Value{"a"}, false},
+		{"ForAllValues:StringEquals", Value{"a", "b"}, Value{"a", "b", "c"}, true},
+		{"ForAllValues:StringEquals", nil, Value{"a"}, true},
+		{"UnknownOperator", Value{"a"}, Value{"a"}, false},
+	}
+	for _, tt := range tests {
+		if evaluateCondition(tt.operator, tt.request, tt.policy) != tt.result {
+			t.Error(tt.operator, tt.request, tt.policy, "expected", tt.result)
+		}
+	}
+}
@@ -1,19 +1,150 @@
 package iam
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Value is a policy element that is either a single string or an array of
+// strings, such as Action, Resource or a condition value. A single value is
+// marshalled back as a string.
+type Value []string
+
+func (v *Value) UnmarshalJSON(b []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	switch t := raw.(type) {
+	case nil:
+		*v = nil
+	case []interface{}:
+		values := make(Value, 0, len(t))
+		for _, item := range t {
+			s, err := scalarString(item)
+			if err != nil {
+				return err
+			}
+			values = append(values, s)
+		}
+		*v = values
+	default:
+		s, err := scalarString(t)
+		if err != nil {
+			return err
+		}
+		*v = Value{s}
+	}
+	return nil
+}
+
+func (v Value) MarshalJSON() ([]byte, error) {
+	if len(v) == 1 {
+		return json.Marshal(v[0])
+	}
+	return json.Marshal([]string(v))
+}
+
+// scalarString converts condition values such as true or 10 to strings, IAM
+// compares them as strings anyway.
+func scalarString(item interface{}) (string, error) {
+	switch t := item.(type) {
+	case string:
+		return t, nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("unexpected policy value %v", item)
+}
+
+// Principal is either "*" (All), or a map of principal types to values.
 type Principal struct {
-	AWS string
+	All           bool  `json:"-"`
+	AWS           Value `json:",omitempty"`
+	Service       Value `json:",omitempty"`
+	Federated     Value `json:",omitempty"`
+	CanonicalUser Value `json:",omitempty"`
 }
 
+// principalFields avoids recursing into Principal's own (Un)MarshalJSON
+type principalFields Principal
+
+func (p *Principal) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if s != "*" {
+			return fmt.Errorf("unexpected principal %q", s)
+		}
+		*p = Principal{All: true}
+		return nil
+	}
+	var fields principalFields
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&fields); err != nil {
+		return fmt.Errorf("unexpected principal %s: %w", b, err)
+	}
+	*p = Principal(fields)
+	return nil
+}
+
+func (p Principal) MarshalJSON() ([]byte, error) {
+	if p.All {
+		return json.Marshal("*")
+	}
+	return json.Marshal(principalFields(p))
+}
+
+// Condition maps an operator, such as StringEquals, to condition keys and
+// their values.
+type Condition map[string]map[string]Value
+
 type StatementEntry struct {
-	Sid       string
-	Effect    string
-	Action    string
-	Resource  string
-	Principal Principal
+	Sid          string `json:",omitempty"`
+	Effect       string
+	Principal    *Principal `json:",omitempty"`
+	NotPrincipal *Principal `json:",omitempty"`
+	Action       Value      `json:",omitempty"`
+	NotAction    Value      `json:",omitempty"`
+	Resource     Value      `json:",omitempty"`
+	NotResource  Value      `json:",omitempty"`
+	Condition    Condition  `json:",omitempty"`
+}
+
+// Statements is a policy's Statement element, which is either a single
+// statement or an array of statements.
+type Statements []StatementEntry
+
+func (s *Statements) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '{' {
+		var statement StatementEntry
+		if err := json.Unmarshal(b, &statement); err != nil {
+			return err
+		}
+		*s = Statements{statement}
+		return nil
+	}
+	var statements []StatementEntry
+	if err := json.Unmarshal(b, &statements); err != nil {
+		return err
+	}
+	*s = statements
+	return nil
 }
 
 type PolicyDocument struct {
 	Version   string
-	Statement []StatementEntry
-	Id        string
+	Statement Statements
+	Id        string `json:",omitempty"`
+}
+
+func ParsePolicyDocument(policy string) (PolicyDocument, error) {
+	var document PolicyDocument
+	err := json.Unmarshal([]byte(policy), &document)
+	return document, err
 }
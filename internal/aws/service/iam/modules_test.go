@@ -0,0 +1,136 @@
+package iam
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const complexPolicy = `{
+  "Version": "2012-10-17",
+  "Id": "ComplexPolicy",
+  "Statement": [
+    {
+      "Sid": "PublicRead",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": ["s3:GetObject", "s3:GetObjectVersion"],
+      "Resource": "arn:aws:s3:::bucket/public/*"
+    },
+    {
+      "Sid": "DenyInsecureTransport",
+      "Effect": "Deny",
+      "Principal": {"AWS": "*"},
+      "NotAction": "s3:ListBucket",
+      "NotResource": ["arn:aws:s3:::bucket/public/*"],
+      "Condition": {
+        "Bool": {"aws:SecureTransport": false},
+        "NumericLessThan": {"s3:TlsVersion": 1.2},
+        "StringNotEquals": {"aws:PrincipalAccount": ["111122223333", "444455556666"]}
+      }
+    },
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "AWS": ["arn:aws:iam::111122223333:root", "arn:aws:iam::444455556666:role/deploy"],
+        "Service": "cloudfront.amazonaws.com",
+        "Federated": "cognito-identity.amazonaws.com",
+        "CanonicalUser": "79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be"
+      },
+      "Action": "s3:*",
+      "Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"]
+    }
+  ]
+}`
+
+func TestParseComplexPolicy(t *testing.T) {
+	policy, err := ParsePolicyDocument(complexPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Statement) != 3 {
+		t.Fatal("Expected 3 statements, got", len(policy.Statement))
+	}
+	public := policy.Statement[0]
+	if !public.Principal.All || !reflect.DeepEqual(public.Action, Value{"s3:GetObject", "s3:GetObjectVersion"}) {
+		t.Fatal("Unexpected statement", public)
+	}
+	deny := policy.Statement[1]
+	if deny.Principal.All || !reflect.DeepEqual(deny.Principal.AWS, Value{"*"}) {
+		t.Fatal("Unexpected principal", deny.Principal)
+	}
+	if !reflect.DeepEqual(deny.Condition["Bool"]["aws:SecureTransport"], Value{"false"}) {
+		t.Fatal("Unexpected condition", deny.Condition)
+	}
+	if !reflect.DeepEqual(deny.Condition["NumericLessThan"]["s3:TlsVersion"], Value{"1.2"}) {
+		t.Fatal("Unexpected condition", deny.Condition)
+	}
+	if len(deny.Condition["StringNotEquals"]["aws:PrincipalAccount"]) != 2 {
+		t.Fatal("Unexpected condition", deny.Condition)
+	}
+	principals := policy.Statement[2].Principal
+	if len(principals.AWS) != 2 || len(principals.Service) != 1 || len(principals.Federated) != 1 || len(principals.CanonicalUser) != 1 {
+		t.Fatal("Unexpected principals", principals)
+	}
+}
+
+func TestPolicyRoundTrip(t *testing.T) {
+	for _, p := range []string{complexPolicy, oacPolicy} {
+		policy, err := ParsePolicyDocument(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roundTrip, err := ParsePolicyDocument(string(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(policy, roundTrip) {
+			t.Fatal("Policy changed after a round trip\n", policy, "\n", roundTrip)
+		}
+	}
+}
+
+func TestPolicyWithoutIdRoundTrip(t *testing.T) {
+	policy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`
+	document, err := ParsePolicyDocument(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != policy {
+		t.Fatal("Unexpected JSON", string(b))
+	}
+}
+
+func TestSingleValuesMarshalAsStrings(t *testing.T) {
+	b, err := json.Marshal(StatementEntry{
+		Effect:    "Allow",
+		Principal: &Principal{All: true},
+		Action:    Value{"s3:GetObject"},
+		Resource:  Value{"arn:aws:s3:::bucket/*", "arn:aws:s3:::bucket"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":["arn:aws:s3:::bucket/*","arn:aws:s3:::bucket"]}`
+	if string(b) != expected {
+		t.Fatal("Unexpected JSON", string(b))
+	}
+}
+
+func TestSingleStatementObject(t *testing.T) {
+	policy, err := ParsePolicyDocument(`{"Version": "2012-10-17", "Statement": {"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*", "Principal": "*"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Statement) != 1 || !policy.Statement[0].Principal.All {
+		t.Fatal("Unexpected statements", policy.Statement)
+	}
+}
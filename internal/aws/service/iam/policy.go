@@ -0,0 +1,185 @@
+package iam
+
+import (
+	"strings"
+)
+
+const cloudFrontOaiArnPrefix = "arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity "
+const CloudFrontServicePrincipal = "cloudfront.amazonaws.com"
+
+// OriginAccessIdentityArn returns the IAM ARN that bucket policies use to
+// grant the CloudFront origin access identity id
+func OriginAccessIdentityArn(id string) string {
+	return cloudFrontOaiArnPrefix + id
+}
+
+// IsOriginAccessIdentityArn reports whether arn is the IAM ARN of a CloudFront
+// origin access identity
+func IsOriginAccessIdentityArn(arn string) bool {
+	return strings.HasPrefix(arn, cloudFrontOaiArnPrefix)
+}
+
+// Condition operators that can restrict aws:SourceArn to a distribution
+var sourceArnOperators = []string{"StringEquals", "StringLike", "ArnEquals", "ArnLike"}
+
+// contains reports whether any of the patterns matches value
+func (v Value) contains(value string, caseSensitive bool) bool {
+	for _, pattern := range v {
+		if !caseSensitive {
+			pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+		}
+		if MatchWildcard(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsAction reports whether an Allow statement applies to action, actions
+// are case insensitive.
+func (s StatementEntry) allowsAction(action string) bool {
+	if s.Effect != "Allow" {
+		return false
+	}
+	if len(s.NotAction) > 0 {
+		return !s.NotAction.contains(action, false)
+	}
+	return s.Action.contains(action, false)
+}
+
+// CloudFrontOriginAccessIdentities returns the ids of the CloudFront origin
+// access identities that are allowed to get objects by the policy.
+func (p PolicyDocument) CloudFrontOriginAccessIdentities() []string {
+	var ids []string
+	for _, s := range p.Statement {
+		if !s.allowsAction("s3:GetObject") || s.Principal == nil {
+			continue
+		}
+		for _, arn := range s.Principal.AWS {
+			if IsOriginAccessIdentityArn(arn) {
+				ids = append(ids, strings.TrimPrefix(arn, cloudFrontOaiArnPrefix))
+			}
+		}
+	}
+	return ids
+}
+
+// sourceArnMatchers compare a distribution ARN with the aws:SourceArn values
+// of each operator
+var sourceArnMatchers = map[string]comparator{
+	"StringEquals": func(r, p string) bool { return r == p },
+	"StringLike":   func(r, p string) bool { return MatchWildcard(p, r) },
+	"ArnEquals":    arnLike,
+	"ArnLike":      arnLike,
+}
+
+// sourceArnCondition is an aws:SourceArn value and its operator
+type sourceArnCondition struct {
+	operator string
+	value    string
+}
+
+// wildcard reports whether the value is a pattern, StringEquals compares it
+// as a literal string
+func (c sourceArnCondition) wildcard() bool {
+	expands := strings.HasSuffix(c.operator, "Like") || strings.HasPrefix(c.operator, "Arn")
+	return expands && strings.ContainsAny(c.value, "*?")
+}
+
+func (s StatementEntry) sourceArns() []sourceArnCondition {
+	var conditions []sourceArnCondition
+	for _, operator := range sourceArnOperators {
+		for key, values := range s.Condition[operator] {
+			if strings.EqualFold(key, "aws:SourceArn") {
+				for _, value := range values {
+					conditions = append(conditions, sourceArnCondition{operator: operator, value: value})
+				}
+			}
+		}
+	}
+	return conditions
+}
+
+// cloudFrontSourceArns returns the aws:SourceArn conditions of the statements
+// that allow the CloudFront service principal to get objects
+func (p PolicyDocument) cloudFrontSourceArns() (conditions []sourceArnCondition, unrestricted bool) {
+	for _, s := range p.Statement {
+		if !s.allowsAction("s3:GetObject") || s.Principal == nil || !s.Principal.Service.contains(CloudFrontServicePrincipal, false) {
+			continue
+		}
+		sourceArns := s.sourceArns()
+		if len(sourceArns) == 0 {
+			unrestricted = true
+		}
+		conditions = append(conditions, sourceArns...)
+	}
+	return conditions, unrestricted
+}
+
+// CloudFrontSourceArns returns the distribution ARNs that are allowed to get
+// objects through an origin access control. unrestricted is true when a
+// statement allows the CloudFront service principal without an aws:SourceArn
+// condition, which grants access to every distribution of every account.
+func (p PolicyDocument) CloudFrontSourceArns() (arns []string, unrestricted bool) {
+	conditions, unrestricted := p.cloudFrontSourceArns()
+	for _, c := range conditions {
+		arns = append(arns, c.value)
+	}
+	return arns, unrestricted
+}
+
+// CloudFrontWildcardSourceArns returns the aws:SourceArn patterns of the Like
+// and Arn operators that contain wildcards, each of them allows more than a single
+// distribution.
+func (p PolicyDocument) CloudFrontWildcardSourceArns() []string {
+	var patterns []string
+	conditions, _ := p.cloudFrontSourceArns()
+	for _, c := range conditions {
+		if c.wildcard() {
+			patterns = append(patterns, c.value)
+		}
+	}
+	return patterns
+}
+
+// MatchWildcard matches value against an IAM pattern, where * matches any
+// sequence of characters and ? matches a single character.
+func MatchWildcard(pattern string, value string) bool {
+	p, v := 0, 0
+	star, match := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, match = p, v
+			p++
+		case star >= 0:
+			// Let the last * consume one more character
+			match++
+			p, v = star+1, match
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// AllowsCloudFrontDistribution reports whether the distribution can get
+// objects through an origin access control.
+func (p PolicyDocument) AllowsCloudFrontDistribution(distributionArn string) bool {
+	conditions, unrestricted := p.cloudFrontSourceArns()
+	if unrestricted {
+		return true
+	}
+	for _, c := range conditions {
+		if sourceArnMatchers[c.operator](distributionArn, c.value) {
+			return true
+		}
+	}
+	return false
+}
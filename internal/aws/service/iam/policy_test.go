@@ -0,0 +1,98 @@
+package iam
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const oacPolicy = `{
+  "Version": "2008-10-17",
+  "Id": "PolicyForCloudFrontPrivateContent",
+  "Statement": [
+    {
+      "Sid": "AllowCloudFrontServicePrincipal",
+      "Effect": "Allow",
+      "Principal": {
+        "Service": "cloudfront.amazonaws.com"
+      },
+      "Action": "s3:GetObject",
+      "Resource": "arn:aws:s3:::dev.example.com/*",
+      "Condition": {
+        "StringEquals": {
+          "AWS:SourceArn": "arn:aws:cloudfront::111122223333:distribution/EDFDVBD6EXAMPLE"
+        }
+      }
+    }
+  ]
+}`
+
+func TestMatchWildcard(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		match   bool
+	}{
+		{"s3:GetObject", "s3:GetObject", true},
+		{"s3:Get*", "s3:GetObject", true},
+		{"s3:*Object", "s3:GetObject", true},
+		{"s3:Get?bject", "s3:GetObject", true},
+		{"s3:Put*", "s3:GetObject", false},
+		{"arn:aws:cloudfront::*:distribution/*", "arn:aws:cloudfront::111122223333:distribution/E1", true},
+		{"*", "", true},
+		{"?", "", false},
+	}
+	for _, tt := range tests {
+		if MatchWildcard(tt.pattern, tt.value) != tt.match {
+			t.Error("Pattern", tt.pattern, "value", tt.value, "expected match", tt.match)
+		}
+	}
+}
+
+func TestCloudFrontSourceArns(t *testing.T) {
+	var policy PolicyDocument
+	if err := json.Unmarshal([]byte(oacPolicy), &policy); err != nil {
+		t.Fatal(err)
+	}
+	arns, unrestricted := policy.CloudFrontSourceArns()
+	if unrestricted || len(arns) != 1 || arns[0] != "arn:aws:cloudfront::111122223333:distribution/EDFDVBD6EXAMPLE" {
+		t.Fatal("Unexpected source ARNs", arns, unrestricted)
+	}
+	if !policy.AllowsCloudFrontDistribution("arn:aws:cloudfront::111122223333:distribution/EDFDVBD6EXAMPLE") {
+		t.Fatal("Expected the distribution to be allowed")
+	}
+	if policy.AllowsCloudFrontDistribution("arn:aws:cloudfront::111122223333:distribution/EOTHER") {
+		t.Fatal("Expected another distribution to be denied")
+	}
+}
+
+func TestAllowsCloudFrontDistributionOperators(t *testing.T) {
+	distributionArn := "arn:aws:cloudfront::111122223333:distribution/E123"
+	tests := []struct {
+		operator string
+		value    string
+		allowed  bool
+		wildcard bool
+	}{
+		{"StringEquals", distributionArn, true, false},
+		{"StringEquals", "arn:aws:cloudfront::111122223333:distribution/*", false, false},
+		{"ArnEquals", "arn:aws:cloudfront::111122223333:distribution/*", true, true},
+		{"ArnEquals", distributionArn, true, false},
+		{"StringLike", "arn:aws:cloudfront::111122223333:distribution/*", true, true},
+		{"ArnLike", "arn:aws:cloudfront::*:distribution/*", true, true},
+		{"ArnLike", distributionArn, true, false},
+	}
+	for _, tt := range tests {
+		policy := PolicyDocument{Statement: Statements{{
+			Effect:    "Allow",
+			Action:    Value{"s3:GetObject"},
+			Principal: &Principal{Service: Value{CloudFrontServicePrincipal}},
+			Condition: Condition{tt.operator: {"AWS:SourceArn": {tt.value}}},
+		}}}
+		if policy.AllowsCloudFrontDistribution(distributionArn) != tt.allowed {
+			t.Error(tt.operator, tt.value, "expected allowed", tt.allowed)
+		}
+		if wildcards := policy.CloudFrontWildcardSourceArns(); (len(wildcards) > 0) != tt.wildcard {
+			t.Error(tt.operator, tt.value, "expected wildcard", tt.wildcard, "got", wildcards)
+		}
+	}
+}
@@ -2,43 +2,294 @@ package route53
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
 	cdns "github.com/unfor19/columbus-app/pkg/dns"
 )
 
-func GetRoute53Record(cfg aws.Config, requestUrl string, domainName string) string {
-	registeredDomainName := cdns.GetRegisteredDomainName(requestUrl)
-	params := route53.ListHostedZonesByNameInput{
-		DNSName: &registeredDomainName,
+// api is the part of the Route53 client that is used, tests replace it
+type api interface {
+	ListHostedZonesByName(ctx context.Context, params *route53.ListHostedZonesByNameInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByNameOutput, error)
+	ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error)
+	GetHostedZone(ctx context.Context, params *route53.GetHostedZoneInput, optFns ...func(*route53.Options)) (*route53.GetHostedZoneOutput, error)
+}
+
+type AliasTarget struct {
+	DNSName              string
+	HostedZoneId         string
+	EvaluateTargetHealth bool
+}
+
+// Route53Record is a record set of a hosted zone
+type Route53Record struct {
+	ZoneName    string
+	ZoneId      string
+	PrivateZone bool
+	Name        string
+	Type        string
+	Ttl         int64        `json:",omitempty"`
+	Values      []string     `json:",omitempty"`
+	AliasTarget *AliasTarget `json:",omitempty"`
+	// RoutingPolicy is simple, weighted, latency, failover, geolocation or
+	// multivalue
+	RoutingPolicy string
+	SetIdentifier string `json:",omitempty"`
+	Weight        *int64 `json:",omitempty"`
+	Region        string `json:",omitempty"`
+	Failover      string `json:",omitempty"`
+	GeoLocation   string `json:",omitempty"`
+	HealthCheckId string `json:",omitempty"`
+}
+
+// ZoneCandidates returns the names of the hosted zones that may serve
+// domainName, the most specific first, from domainName itself up to its
+// registered domain. Delegated subdomains have their own hosted zone.
+func ZoneCandidates(domainName string) ([]string, error) {
+	domainName = strings.ToLower(strings.TrimSuffix(domainName, "."))
+	registeredDomainName, err := cdns.RegisteredDomain(domainName)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []string
+	for name := domainName; ; {
+		candidates = append(candidates, name)
+		if name == registeredDomainName {
+			return candidates, nil
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			return candidates, nil
+		}
+		name = name[i+1:]
+	}
+}
+
+// unescapeName decodes the octal escapes of Route53 names, such as \052 for *
+func unescapeName(name string) string {
+	if !strings.Contains(name, `\`) {
+		return strings.ToLower(name)
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+4 <= len(name) {
+			if c, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return strings.ToLower(b.String())
+}
+
+// hostedZones returns the public and private zones named name
+func hostedZones(ctx context.Context, svc api, name string) ([]types.HostedZone, error) {
+	var zones []types.HostedZone
+	params := &route53.ListHostedZonesByNameInput{DNSName: aws.String(name)}
+	for {
+		resp, err := svc.ListHostedZonesByName(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		// The zones are sorted by name, so zones named name come first
+		for _, zone := range resp.HostedZones {
+			if unescapeName(aws.ToString(zone.Name)) != name+"." {
+				return zones, nil
+			}
+			zones = append(zones, zone)
+		}
+		if !resp.IsTruncated {
+			return zones, nil
+		}
+		params.DNSName, params.HostedZoneId = resp.NextDNSName, resp.NextHostedZoneId
+	}
+}
+
+func getHostedZones(ctx context.Context, svc api, domainName string) ([]types.HostedZone, error) {
+	candidates, err := ZoneCandidates(domainName)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range candidates {
+		zones, err := hostedZones(ctx, svc, candidate)
+		if err != nil || len(zones) > 0 {
+			return zones, err
+		}
 	}
+	return nil, nil
+}
+
+// GetHostedZones returns the public and private hosted zones with the most
+// specific name that serves domainName
+func GetHostedZones(ctx context.Context, cfg aws.Config, domainName string) ([]types.HostedZone, error) {
+	return getHostedZones(ctx, route53.NewFromConfig(cfg), domainName)
+}
+
+// IsPrivate reports whether the zone is a private hosted zone
+func IsPrivate(zone types.HostedZone) bool {
+	return zone.Config != nil && zone.Config.PrivateZone
+}
+
+// GetDelegationSet returns the name servers Route53 assigned to the hosted zone
+func GetDelegationSet(ctx context.Context, cfg aws.Config, hostedZoneId *string) ([]string, error) {
 	svc := route53.NewFromConfig(cfg)
-	resp, err := svc.ListHostedZonesByName(context.TODO(), &params)
+	resp, err := svc.GetHostedZone(ctx, &route53.GetHostedZoneInput{Id: hostedZoneId})
 	if err != nil {
-		log.Println(err)
-		return "none"
+		return nil, err
 	}
+	if resp.DelegationSet == nil {
+		return nil, nil
+	}
+	return resp.DelegationSet.NameServers, nil
+}
 
-	if len(resp.HostedZones) == 1 && strings.Contains(*resp.HostedZones[0].Name, registeredDomainName) {
-		hostedZoneId := resp.HostedZones[0].Id
-		params := route53.ListResourceRecordSetsInput{
-			HostedZoneId: hostedZoneId,
+func newRoute53Record(zone types.HostedZone, r types.ResourceRecordSet) Route53Record {
+	record := Route53Record{
+		ZoneName:      aws.ToString(zone.Name),
+		ZoneId:        strings.TrimPrefix(aws.ToString(zone.Id), "/hostedzone/"),
+		PrivateZone:   IsPrivate(zone),
+		Name:          unescapeName(aws.ToString(r.Name)),
+		Type:          string(r.Type),
+		Ttl:           aws.ToInt64(r.TTL),
+		SetIdentifier: aws.ToString(r.SetIdentifier),
+		Weight:        r.Weight,
+		Region:        string(r.Region),
+		Failover:      string(r.Failover),
+		HealthCheckId: aws.ToString(r.HealthCheckId),
+	}
+	for _, v := range r.ResourceRecords {
+		record.Values = append(record.Values, aws.ToString(v.Value))
+	}
+	if r.AliasTarget != nil {
+		record.AliasTarget = &AliasTarget{
+			DNSName:              aws.ToString(r.AliasTarget.DNSName),
+			HostedZoneId:         aws.ToString(r.AliasTarget.HostedZoneId),
+			EvaluateTargetHealth: r.AliasTarget.EvaluateTargetHealth,
 		}
-		resp, err := svc.ListResourceRecordSets(context.TODO(), &params)
-		if err != nil {
-			log.Fatalln(err)
+	}
+	if g := r.GeoLocation; g != nil {
+		var parts []string
+		for _, p := range []*string{g.ContinentCode, g.CountryCode, g.SubdivisionCode} {
+			if aws.ToString(p) != "" {
+				parts = append(parts, aws.ToString(p))
+			}
 		}
+		record.GeoLocation = strings.Join(parts, "/")
+	}
+	switch {
+	case r.Weight != nil:
+		record.RoutingPolicy = "weighted"
+	case r.Region != "":
+		record.RoutingPolicy = "latency"
+	case r.Failover != "":
+		record.RoutingPolicy = "failover"
+	case r.GeoLocation != nil:
+		record.RoutingPolicy = "geolocation"
+	case aws.ToBool(r.MultiValueAnswer):
+		record.RoutingPolicy = "multivalue"
+	default:
+		record.RoutingPolicy = "simple"
+	}
+	return record
+}
 
+// listRecordSets returns every record set of the zone named name, paging
+// from the first one, as the record sets are sorted by name. All the record
+// sets of the zone are returned when name is empty.
+func listRecordSets(ctx context.Context, svc api, zone types.HostedZone, name string) ([]Route53Record, error) {
+	params := &route53.ListResourceRecordSetsInput{HostedZoneId: zone.Id}
+	if name != "" {
+		// Route53 returns the * of wildcard names as \052
+		params.StartRecordName = aws.String(strings.Replace(name, "*", `\052`, 1))
+	}
+	var records []Route53Record
+	for {
+		resp, err := svc.ListResourceRecordSets(ctx, params)
+		if err != nil {
+			return nil, err
+		}
 		for _, r := range resp.ResourceRecordSets {
-			if fmt.Sprint(domainName, ".") == *r.Name {
-				return *r.Name
+			if name != "" && unescapeName(aws.ToString(r.Name)) != name {
+				return records, nil
 			}
+			records = append(records, newRoute53Record(zone, r))
+		}
+		if !resp.IsTruncated {
+			return records, nil
 		}
+		params.StartRecordName = resp.NextRecordName
+		params.StartRecordType = resp.NextRecordType
+		params.StartRecordIdentifier = resp.NextRecordIdentifier
+	}
+}
+
+// recordNames returns domainName, then the wildcard names of the zone that
+// may cover it, the closest first
+func recordNames(zone types.HostedZone, domainName string) []string {
+	name := strings.ToLower(strings.TrimSuffix(domainName, ".")) + "."
+	zoneName := unescapeName(aws.ToString(zone.Name))
+	names := []string{name}
+	if !strings.HasSuffix("."+name, "."+zoneName) {
+		return names
+	}
+	for name != zoneName {
+		name = name[strings.IndexByte(name, '.')+1:]
+		names = append(names, "*."+name)
 	}
+	return names
+}
+
+// listRecords returns the record sets of domainName in the zone, or those of
+// the closest wildcard name when the zone has none for domainName. All the
+// record sets of the zone are returned when domainName is empty.
+func listRecords(ctx context.Context, svc api, zone types.HostedZone, domainName string) ([]Route53Record, error) {
+	if domainName == "" {
+		return listRecordSets(ctx, svc, zone, "")
+	}
+	for _, name := range recordNames(zone, domainName) {
+		records, err := listRecordSets(ctx, svc, zone, name)
+		if err != nil || len(records) > 0 {
+			return records, err
+		}
+	}
+	return nil, nil
+}
+
+// ListZoneRecords returns all the record sets of the zone
+func ListZoneRecords(ctx context.Context, cfg aws.Config, zone types.HostedZone) ([]Route53Record, error) {
+	return listRecords(ctx, route53.NewFromConfig(cfg), zone, "")
+}
+
+// ZoneRecords are the record sets of a domain in one of its hosted zones
+type ZoneRecords struct {
+	Zone    types.HostedZone
+	Records []Route53Record
+}
+
+func getZoneRecords(ctx context.Context, svc api, domainName string) ([]ZoneRecords, error) {
+	zones, err := getHostedZones(ctx, svc, domainName)
+	if err != nil {
+		return nil, err
+	}
+	var zoneRecords []ZoneRecords
+	for _, zone := range zones {
+		records, err := listRecords(ctx, svc, zone, domainName)
+		if err != nil {
+			return zoneRecords, err
+		}
+		zoneRecords = append(zoneRecords, ZoneRecords{Zone: zone, Records: records})
+	}
+	return zoneRecords, nil
+}
 
-	return "none"
+// GetZoneRecords returns the record sets of domainName, or of the wildcard
+// name that covers it, in all the public and private hosted zones with the
+// most specific name that serves it
+func GetZoneRecords(ctx context.Context, cfg aws.Config, domainName string) ([]ZoneRecords, error) {
+	return getZoneRecords(ctx, route53.NewFromConfig(cfg), domainName)
 }
@@ -0,0 +1,151 @@
+package route53
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+func TestZoneCandidates(t *testing.T) {
+	candidates, err := ZoneCandidates("App.Dev.Example.co.uk.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"app.dev.example.co.uk", "dev.example.co.uk", "example.co.uk"}
+	if !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("expected %v, got %v", expected, candidates)
+	}
+	if _, err := ZoneCandidates("co.uk"); err == nil {
+		t.Error("expected an error for a public suffix")
+	}
+}
+
+// fakeApi serves the zones and record sets one per page
+type fakeApi struct {
+	zones   []types.HostedZone
+	records map[string][]types.ResourceRecordSet
+}
+
+func (f fakeApi) ListHostedZonesByName(ctx context.Context, params *route53.ListHostedZonesByNameInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByNameOutput, error) {
+	start := 0
+	for start < len(f.zones) && (aws.ToString(f.zones[start].Name) < strings.TrimSuffix(aws.ToString(params.DNSName), ".")+"." ||
+		(params.HostedZoneId != nil && aws.ToString(f.zones[start].Id) != aws.ToString(params.HostedZoneId))) {
+		start++
+	}
+	out := &route53.ListHostedZonesByNameOutput{HostedZones: f.zones[start:]}
+	if start+1 < len(f.zones) {
+		out.HostedZones = f.zones[start : start+1]
+		out.IsTruncated = true
+		out.NextDNSName = f.zones[start+1].Name
+		out.NextHostedZoneId = f.zones[start+1].Id
+	}
+	return out, nil
+}
+
+func (f fakeApi) ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error) {
+	records := f.records[aws.ToString(params.HostedZoneId)]
+	start := 0
+	for start < len(records) && (aws.ToString(records[start].Name) < aws.ToString(params.StartRecordName) ||
+		(params.StartRecordIdentifier != nil && aws.ToString(records[start].SetIdentifier) != aws.ToString(params.StartRecordIdentifier))) {
+		start++
+	}
+	out := &route53.ListResourceRecordSetsOutput{ResourceRecordSets: records[start:]}
+	if start+1 < len(records) {
+		out.ResourceRecordSets = records[start : start+1]
+		out.IsTruncated = true
+		out.NextRecordName = records[start+1].Name
+		out.NextRecordType = records[start+1].Type
+		out.NextRecordIdentifier = records[start+1].SetIdentifier
+	}
+	return out, nil
+}
+
+func (f fakeApi) GetHostedZone(ctx context.Context, params *route53.GetHostedZoneInput, optFns ...func(*route53.Options)) (*route53.GetHostedZoneOutput, error) {
+	return &route53.GetHostedZoneOutput{}, nil
+}
+
+func TestGetZoneRecords(t *testing.T) {
+	svc := fakeApi{
+		zones: []types.HostedZone{
+			{Name: aws.String("example.com."), Id: aws.String("/hostedzone/Z1PUBLIC")},
+			{Name: aws.String("example.com."), Id: aws.String("/hostedzone/Z2PRIVATE"), Config: &types.HostedZoneConfig{PrivateZone: true}},
+			{Name: aws.String("example.org."), Id: aws.String("/hostedzone/Z3OTHER")},
+		},
+		records: map[string][]types.ResourceRecordSet{
+			"/hostedzone/Z1PUBLIC": {
+				{Name: aws.String(`\052.example.com.`), Type: types.RRTypeCname, TTL: aws.Int64(300),
+					ResourceRecords: []types.ResourceRecord{{Value: aws.String("d333333abcdef8.cloudfront.net")}}},
+				{Name: aws.String("api.example.com."), Type: types.RRTypeA, SetIdentifier: aws.String("eu"), Region: types.ResourceRecordSetRegionEuWest1,
+					AliasTarget: &types.AliasTarget{DNSName: aws.String("d111111abcdef8.cloudfront.net."), HostedZoneId: aws.String("Z2FDTNDATAQYW2")}},
+				{Name: aws.String("www.example.com."), Type: types.RRTypeA, SetIdentifier: aws.String("blue"), Weight: aws.Int64(90),
+					AliasTarget: &types.AliasTarget{DNSName: aws.String("d111111abcdef8.cloudfront.net."), HostedZoneId: aws.String("Z2FDTNDATAQYW2")}},
+				{Name: aws.String("www.example.com."), Type: types.RRTypeCname, SetIdentifier: aws.String("green"), Weight: aws.Int64(10), TTL: aws.Int64(60),
+					ResourceRecords: []types.ResourceRecord{{Value: aws.String("d222222abcdef8.cloudfront.net")}}},
+				{Name: aws.String("zz.example.com."), Type: types.RRTypeA},
+			},
+			"/hostedzone/Z2PRIVATE": {
+				{Name: aws.String("www.example.com."), Type: types.RRTypeA, TTL: aws.Int64(300),
+					ResourceRecords: []types.ResourceRecord{{Value: aws.String("10.0.0.1")}, {Value: aws.String("10.0.0.2")}}},
+			},
+		},
+	}
+	zones, err := getZoneRecords(context.Background(), svc, "WWW.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 2 || aws.ToString(zones[0].Zone.Id) != "/hostedzone/Z1PUBLIC" || len(zones[0].Records) != 2 || len(zones[1].Records) != 1 {
+		t.Fatalf("expected the records of both zones, got %+v", zones)
+	}
+	records := append(zones[0].Records, zones[1].Records...)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %+v", records)
+	}
+	if r := records[0]; r.ZoneId != "Z1PUBLIC" || r.RoutingPolicy != "weighted" || aws.ToInt64(r.Weight) != 90 || r.AliasTarget == nil || r.AliasTarget.DNSName != "d111111abcdef8.cloudfront.net." {
+		t.Errorf("unexpected alias record %+v", r)
+	}
+	if r := records[1]; r.SetIdentifier != "green" || r.Ttl != 60 || !reflect.DeepEqual(r.Values, []string{"d222222abcdef8.cloudfront.net"}) {
+		t.Errorf("unexpected CNAME record %+v", r)
+	}
+	if r := records[2]; !r.PrivateZone || r.RoutingPolicy != "simple" || len(r.Values) != 2 {
+		t.Errorf("unexpected private record %+v", r)
+	}
+
+	records, err = listRecords(context.Background(), svc, svc.zones[0], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 {
+		t.Errorf("expected all 5 records of the zone, got %+v", records)
+	}
+
+	// Names without records of their own are served by the wildcard record
+	records, err = listRecords(context.Background(), svc, svc.zones[0], "app.dev.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Name != "*.example.com." || records[0].Values[0] != "d333333abcdef8.cloudfront.net" {
+		t.Errorf("expected the wildcard record, got %+v", records)
+	}
+}
+
+func TestRecordNames(t *testing.T) {
+	zone := types.HostedZone{Name: aws.String("example.com.")}
+	expected := []string{"app.dev.example.com.", "*.dev.example.com.", "*.example.com."}
+	if names := recordNames(zone, "App.Dev.example.com"); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+	if names := recordNames(zone, "example.org"); !reflect.DeepEqual(names, []string{"example.org."}) {
+		t.Errorf("expected no wildcard names outside of the zone, got %v", names)
+	}
+}
+
+func TestUnescapeName(t *testing.T) {
+	if name := unescapeName(`\052.Example.com.`); name != "*.example.com." {
+		t.Errorf("unexpected name %q", name)
+	}
+}
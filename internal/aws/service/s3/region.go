@@ -0,0 +1,113 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// BucketEndpoint is an S3 endpoint that a CloudFront origin points to
+type BucketEndpoint struct {
+	Bucket string
+	// Region is empty for the legacy global endpoint, bucket.s3.amazonaws.com
+	Region  string
+	Website bool
+}
+
+// Matches the REST endpoints, bucket.s3.amazonaws.com, bucket.s3.region.
+// amazonaws.com, bucket.s3-region.amazonaws.com and bucket.s3.dualstack.
+// region.amazonaws.com, and the website endpoints, bucket.s3-website-region.
+// amazonaws.com and bucket.s3-website.region.amazonaws.com.
+var bucketEndpointPattern = regexp.MustCompile(`^(.+?)\.s3(-website)?(?:[.-](?:dualstack\.)?([a-z]{2}(?:-gov|-iso[a-z]?)?-[a-z]+-\d+))?\.amazonaws\.com(?:\.cn)?\.?$`)
+
+// ParseBucketEndpoint extracts the bucket name and region from an S3 domain
+// name, ok is false when domainName is not an S3 endpoint.
+func ParseBucketEndpoint(domainName string) (endpoint BucketEndpoint, ok bool) {
+	m := bucketEndpointPattern.FindStringSubmatch(domainName)
+	if m == nil {
+		return BucketEndpoint{}, false
+	}
+	return BucketEndpoint{Bucket: m[1], Region: m[3], Website: m[2] != ""}, true
+}
+
+// Buckets cannot move between regions, their regions are kept for the
+// lifetime of the process.
+var bucketRegions sync.Map
+
+var bucketRegionClient = &http.Client{
+	Timeout: 10 * time.Second,
+	// The redirect to the bucket's region has the header already
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// bucketRegionHeader reads the X-Amz-Bucket-Region header that S3 returns on
+// any HEAD request to a bucket, including unauthorized ones.
+func bucketRegionHeader(ctx context.Context, bucketName string) (string, error) {
+	// Path style, bucket names with dots do not match the virtual host certificate
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "https://s3.amazonaws.com/"+bucketName, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := bucketRegionClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	region := resp.Header.Get("X-Amz-Bucket-Region")
+	if region == "" {
+		return "", fmt.Errorf("no bucket region header for %s, status code %d", bucketName, resp.StatusCode)
+	}
+	return region, nil
+}
+
+func bucketLocation(ctx context.Context, cfg aws.Config, bucketName string) (string, error) {
+	svc := s3.NewFromConfig(cfg)
+	resp, err := svc.GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: &bucketName})
+	if err != nil {
+		return "", err
+	}
+	switch resp.LocationConstraint {
+	case "":
+		return "us-east-1", nil
+	case "EU":
+		return "eu-west-1", nil
+	}
+	return string(resp.LocationConstraint), nil
+}
+
+// GetS3BucketRegion discovers the region of a bucket from the
+// X-Amz-Bucket-Region header, or GetBucketLocation when the header is
+// missing.
+func GetS3BucketRegion(ctx context.Context, cfg aws.Config, bucketName string) (string, error) {
+	if region, ok := bucketRegions.Load(bucketName); ok {
+		return region.(string), nil
+	}
+	region, err := bucketRegionHeader(ctx, bucketName)
+	if err != nil {
+		var locationErr error
+		region, locationErr = bucketLocation(ctx, cfg, bucketName)
+		if locationErr != nil {
+			return "", fmt.Errorf("failed to discover the region of bucket %s: %v, %w", bucketName, err, locationErr)
+		}
+	}
+	bucketRegions.Store(bucketName, region)
+	return region, nil
+}
+
+// ConfigForRegion returns a copy of cfg for clients of another region
+func ConfigForRegion(cfg aws.Config, region string) aws.Config {
+	if region == "" || region == cfg.Region {
+		return cfg
+	}
+	regional := cfg.Copy()
+	regional.Region = region
+	return regional
+}
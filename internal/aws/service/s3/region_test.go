@@ -0,0 +1,30 @@
+package s3
+
+import "testing"
+
+func TestParseBucketEndpoint(t *testing.T) {
+	tests := map[string]BucketEndpoint{
+		"dev.sokker.info.s3.amazonaws.com":                      {Bucket: "dev.sokker.info"},
+		"dev.sokker.info.s3.eu-west-1.amazonaws.com":            {Bucket: "dev.sokker.info", Region: "eu-west-1"},
+		"my-bucket.s3.us-east-2.amazonaws.com":                  {Bucket: "my-bucket", Region: "us-east-2"},
+		"my-bucket.s3-ap-southeast-1.amazonaws.com":             {Bucket: "my-bucket", Region: "ap-southeast-1"},
+		"my-bucket.s3.dualstack.eu-central-1.amazonaws.com":     {Bucket: "my-bucket", Region: "eu-central-1"},
+		"my-bucket.s3.us-gov-west-1.amazonaws.com":              {Bucket: "my-bucket", Region: "us-gov-west-1"},
+		"my-bucket.s3.cn-north-1.amazonaws.com.cn":              {Bucket: "my-bucket", Region: "cn-north-1"},
+		"my-bucket.s3-website-us-east-1.amazonaws.com":          {Bucket: "my-bucket", Region: "us-east-1", Website: true},
+		"dev.sokker.info.s3-website.eu-west-1.amazonaws.com":    {Bucket: "dev.sokker.info", Region: "eu-west-1", Website: true},
+		"dev.sokker.info.s3-website.eu-central-1.amazonaws.com": {Bucket: "dev.sokker.info", Region: "eu-central-1", Website: true},
+	}
+	for domainName, expected := range tests {
+		endpoint, ok := ParseBucketEndpoint(domainName)
+		if !ok || endpoint != expected {
+			t.Errorf("ParseBucketEndpoint(%q) = %+v, %v, expected %+v", domainName, endpoint, ok, expected)
+		}
+	}
+
+	for _, domainName := range []string{"abc123.execute-api.eu-west-1.amazonaws.com", "d111111abcdef8.cloudfront.net", "example.com"} {
+		if endpoint, ok := ParseBucketEndpoint(domainName); ok {
+			t.Errorf("ParseBucketEndpoint(%q) = %+v, expected no match", domainName, endpoint)
+		}
+	}
+}
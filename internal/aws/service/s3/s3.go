@@ -2,21 +2,65 @@ package s3
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
-func GetS3BucketExists(cfg aws.Config, bucketName string) bool {
+func GetS3BucketExists(ctx context.Context, cfg aws.Config, bucketName string) bool {
 	svc := s3.NewFromConfig(cfg)
 	params := s3.HeadBucketInput{
 		Bucket: &bucketName,
 	}
-	_, err := svc.HeadBucket(context.TODO(), &params)
+	_, err := svc.HeadBucket(ctx, &params)
 	if err != nil {
 		log.Println(err)
 		return false
 	}
 	return true
 }
+
+// GetS3BucketMissing reports whether S3 answers that the bucket does not
+// exist. A denied request proves that the bucket exists in an account the
+// credentials cannot access, other errors prove nothing.
+func GetS3BucketMissing(ctx context.Context, cfg aws.Config, bucketName string) (bool, error) {
+	svc := s3.NewFromConfig(cfg)
+	_, err := svc.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &bucketName})
+	if err == nil {
+		return false, nil
+	}
+	var noSuchBucket *types.NoSuchBucket
+	if errors.As(err, &noSuchBucket) {
+		return true, nil
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusNotFound:
+			return true, nil
+		case http.StatusForbidden:
+			return false, nil
+		}
+	}
+	return false, err
+}
+
+// GetS3ObjectETag returns the ETag of the object without the surrounding quotes.
+func GetS3ObjectETag(ctx context.Context, cfg aws.Config, bucketName string, key string) (string, error) {
+	svc := s3.NewFromConfig(cfg)
+	params := s3.HeadObjectInput{
+		Bucket: &bucketName,
+		Key:    &key,
+	}
+	resp, err := svc.HeadObject(ctx, &params)
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(aws.ToString(resp.ETag), "\"", ""), nil
+}
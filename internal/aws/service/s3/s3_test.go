@@ -0,0 +1,52 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestGetS3BucketMissing(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/") {
+		case "existing-bucket":
+			w.WriteHeader(http.StatusOK)
+		case "other-account-bucket":
+			w.WriteHeader(http.StatusForbidden)
+		case "missing-bucket":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		EndpointResolverWithOptions: aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: ts.URL, HostnameImmutable: true}, nil
+		}),
+		Retryer: func() aws.Retryer { return aws.NopRetryer{} },
+	}
+	tests := []struct {
+		bucket  string
+		missing bool
+		err     bool
+	}{
+		{"existing-bucket", false, false},
+		{"other-account-bucket", false, false},
+		{"missing-bucket", true, false},
+		{"unavailable-bucket", false, true},
+	}
+	for _, tt := range tests {
+		missing, err := GetS3BucketMissing(context.Background(), cfg, tt.bucket)
+		if missing != tt.missing || (err != nil) != tt.err {
+			t.Errorf("%s: expected missing %v and error %v, got %v %v", tt.bucket, tt.missing, tt.err, missing, err)
+		}
+	}
+}
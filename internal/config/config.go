@@ -0,0 +1,400 @@
+// Package config loads the Columbus settings from the built-in defaults, an
+// optional YAML or JSON configuration file and COLUMBUS_* environment
+// variables, in that order, and validates them at startup.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unfor19/columbus-app/internal/aws/accounts"
+	"github.com/unfor19/columbus-app/internal/explorer"
+	"github.com/unfor19/columbus-app/internal/insights"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
+	"gopkg.in/yaml.v2"
+)
+
+// Duration is a time.Duration written as a string, such as 90s or 15m
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+type Aws struct {
+	Region string         `yaml:"region"`
+	Roles  accounts.Roles `yaml:"roles"`
+}
+
+type IpRanges struct {
+	FilePath        string   `yaml:"filePath"`
+	Url             string   `yaml:"url"`
+	RefreshInterval Duration `yaml:"refreshInterval"`
+}
+
+type Timeouts struct {
+	Exploration          Duration `yaml:"exploration"`
+	Invalidation         Duration `yaml:"invalidation"`
+	InvalidationInterval Duration `yaml:"invalidationInterval"`
+}
+
+// Jobs sizes the pool of workers that run background jobs
+type Jobs struct {
+	Workers   int      `yaml:"workers"`
+	QueueSize int      `yaml:"queueSize"`
+	Retention Duration `yaml:"retention"`
+}
+
+type Insights struct {
+	// Enabled insights, all of them when empty
+	Enabled  []string `yaml:"enabled"`
+	Disabled []string `yaml:"disabled"`
+}
+
+type Config struct {
+	Listen    string   `yaml:"listen"`
+	Resolvers []string `yaml:"resolvers"`
+	// TraceServer is where delegation traces start, the root servers when empty
+	TraceServer   string   `yaml:"traceServer"`
+	Aws           Aws      `yaml:"aws"`
+	IndexFilePath string   `yaml:"indexFilePath"`
+	IpRanges      IpRanges `yaml:"ipRanges"`
+	// PublicSuffixList replaces the bundled Public Suffix List when it is set
+	PublicSuffixList string   `yaml:"publicSuffixList"`
+	Timeouts         Timeouts `yaml:"timeouts"`
+	Explorations     Jobs     `yaml:"explorations"`
+	// Invalidations have their own pool, as each one polls for minutes
+	Invalidations Jobs     `yaml:"invalidations"`
+	Explorers     []string `yaml:"explorers"`
+	Insights      Insights `yaml:"insights"`
+}
+
+func Default() Config {
+	e := explorer.DefaultConfig()
+	return Config{
+		Listen:        ":8080",
+		Resolvers:     e.DnsServers,
+		Aws:           Aws{Region: e.Region},
+		IndexFilePath: e.IndexFilePath,
+		IpRanges: IpRanges{
+			FilePath:        e.IpRangesFilePath,
+			Url:             e.IpRangesUrl,
+			RefreshInterval: Duration(e.IpRangesRefreshInterval),
+		},
+		Timeouts: Timeouts{
+			Exploration:          Duration(5 * time.Minute),
+			Invalidation:         Duration(15 * time.Minute),
+			InvalidationInterval: Duration(10 * time.Second),
+		},
+		Explorations:  Jobs{Workers: 4, QueueSize: 100, Retention: Duration(time.Hour)},
+		Invalidations: Jobs{Workers: 2, QueueSize: 10, Retention: Duration(time.Hour)},
+		Explorers:     append([]string(nil), explorer.DefaultExplorers...),
+	}
+}
+
+// ValidationError lists every invalid setting, so they can all be fixed at once
+type ValidationError []string
+
+func (v ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(v, "\n  - ")
+}
+
+// Load returns the defaults, overridden by the file at path when it is set,
+// or by the COLUMBUS_CONFIG file, and then by the COLUMBUS_* environment
+// variables. AWS_REGION, which AWS runtimes such as ECS and Lambda set, only
+// replaces the default region, it does not override the file.
+func Load(path string) (Config, error) {
+	c := Default()
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		c.Aws.Region = region
+	}
+	if path == "" {
+		path = os.Getenv("COLUMBUS_CONFIG")
+	}
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return c, err
+		}
+	}
+	if err := c.applyEnv(); err != nil {
+		return c, err
+	}
+	c.normalize()
+	return c, c.Validate()
+}
+
+// loadFile parses a YAML file, or a JSON file as JSON is valid YAML. Unknown
+// settings are an error, to catch typos.
+func (c *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// applyEnv overrides the settings that have a COLUMBUS_* environment variable
+func (c *Config) applyEnv() error {
+	var errs ValidationError
+	env := func(name string, apply func(value string) error) {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return
+		}
+		if err := apply(value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	duration := func(d *Duration) func(string) error {
+		return func(value string) error {
+			v, err := time.ParseDuration(value)
+			*d = Duration(v)
+			return err
+		}
+	}
+	integer := func(i *int) func(string) error {
+		return func(value string) error {
+			v, err := strconv.Atoi(value)
+			*i = v
+			return err
+		}
+	}
+
+	env("COLUMBUS_LISTEN", func(v string) error { c.Listen = v; return nil })
+	env("COLUMBUS_RESOLVERS", func(v string) error { c.Resolvers = splitList(v); return nil })
+	env("COLUMBUS_TRACE_SERVER", func(v string) error { c.TraceServer = v; return nil })
+	env("COLUMBUS_AWS_REGION", func(v string) error { c.Aws.Region = v; return nil })
+	env("COLUMBUS_ROLES", func(v string) error {
+		roles, err := accounts.ParseRoles(v)
+		c.Aws.Roles = roles
+		return err
+	})
+	env("COLUMBUS_INDEX_FILEPATH", func(v string) error { c.IndexFilePath = v; return nil })
+	env("COLUMBUS_IP_RANGES_FILEPATH", func(v string) error { c.IpRanges.FilePath = v; return nil })
+	env("COLUMBUS_IP_RANGES_URL", func(v string) error { c.IpRanges.Url = v; return nil })
+	env("COLUMBUS_IP_RANGES_REFRESH_INTERVAL", duration(&c.IpRanges.RefreshInterval))
+	env("COLUMBUS_PUBLIC_SUFFIX_LIST", func(v string) error { c.PublicSuffixList = v; return nil })
+	env("COLUMBUS_EXPLORATION_TIMEOUT", duration(&c.Timeouts.Exploration))
+	env("COLUMBUS_INVALIDATION_TIMEOUT", duration(&c.Timeouts.Invalidation))
+	env("COLUMBUS_INVALIDATION_INTERVAL", duration(&c.Timeouts.InvalidationInterval))
+	env("COLUMBUS_WORKERS", integer(&c.Explorations.Workers))
+	env("COLUMBUS_QUEUE_SIZE", integer(&c.Explorations.QueueSize))
+	env("COLUMBUS_RETENTION", duration(&c.Explorations.Retention))
+	env("COLUMBUS_INVALIDATION_WORKERS", integer(&c.Invalidations.Workers))
+	env("COLUMBUS_INVALIDATION_QUEUE_SIZE", integer(&c.Invalidations.QueueSize))
+	env("COLUMBUS_INVALIDATION_RETENTION", duration(&c.Invalidations.Retention))
+	env("COLUMBUS_EXPLORERS", func(v string) error { c.Explorers = splitList(v); return nil })
+	env("COLUMBUS_INSIGHTS_ENABLED", func(v string) error { c.Insights.Enabled = splitList(v); return nil })
+	env("COLUMBUS_INSIGHTS_DISABLED", func(v string) error { c.Insights.Disabled = splitList(v); return nil })
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ResolverAddress adds the DNS port when the resolver is a bare address
+func ResolverAddress(resolver string) string {
+	if _, _, err := net.SplitHostPort(resolver); err == nil {
+		return resolver
+	}
+	return net.JoinHostPort(strings.Trim(resolver, "[]"), "53")
+}
+
+func (c *Config) normalize() {
+	for i, r := range c.Resolvers {
+		c.Resolvers[i] = ResolverAddress(strings.TrimSpace(r))
+	}
+	if c.TraceServer != "" {
+		c.TraceServer = ResolverAddress(strings.TrimSpace(c.TraceServer))
+	}
+}
+
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-\d+$`)
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate reports every invalid setting
+func (c Config) Validate() error {
+	var errs ValidationError
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if _, port, err := net.SplitHostPort(c.Listen); err != nil {
+		invalid("listen: %q is not a host:port address, such as :8080", c.Listen)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		invalid("listen: invalid port %q", port)
+	}
+
+	if len(c.Resolvers) == 0 {
+		invalid("resolvers: at least one DNS resolver is required")
+	}
+	for _, r := range c.Resolvers {
+		host, _, err := net.SplitHostPort(r)
+		if err != nil || net.ParseIP(host) == nil {
+			invalid("resolvers: %q is not an IP address, optionally with a port", r)
+		}
+	}
+	if c.TraceServer != "" {
+		if host, _, err := net.SplitHostPort(c.TraceServer); err != nil || net.ParseIP(host) == nil {
+			invalid("traceServer: %q is not an IP address, optionally with a port", c.TraceServer)
+		}
+	}
+
+	if !regionPattern.MatchString(c.Aws.Region) {
+		invalid("aws.region: %q is not an AWS region, such as eu-west-1", c.Aws.Region)
+	}
+	for key, chain := range c.Aws.Roles {
+		if _, _, err := accounts.ParseRole(key + "=" + strings.Join(chain, ",")); err != nil {
+			invalid("aws.roles: %v", err)
+		}
+	}
+
+	if c.IndexFilePath == "" {
+		invalid("indexFilePath: is required")
+	}
+	if c.IpRanges.FilePath == "" {
+		invalid("ipRanges.filePath: is required")
+	} else if info, err := os.Stat(filepath.Dir(c.IpRanges.FilePath)); err != nil || !info.IsDir() {
+		invalid("ipRanges.filePath: directory %q does not exist", filepath.Dir(c.IpRanges.FilePath))
+	}
+	if u, err := url.Parse(c.IpRanges.Url); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		invalid("ipRanges.url: %q is not an http(s) URL", c.IpRanges.Url)
+	}
+
+	if c.PublicSuffixList != "" {
+		if f, err := os.Open(c.PublicSuffixList); err != nil {
+			invalid("publicSuffixList: %v", err)
+		} else {
+			if _, err := cdns.ParsePublicSuffixList(f); err != nil {
+				invalid("publicSuffixList: %v", err)
+			}
+			f.Close()
+		}
+	}
+
+	for name, d := range map[string]Duration{
+		"timeouts.exploration":          c.Timeouts.Exploration,
+		"timeouts.invalidation":         c.Timeouts.Invalidation,
+		"timeouts.invalidationInterval": c.Timeouts.InvalidationInterval,
+		"explorations.retention":        c.Explorations.Retention,
+		"invalidations.retention":       c.Invalidations.Retention,
+		"ipRanges.refreshInterval":      c.IpRanges.RefreshInterval,
+	} {
+		if d <= 0 {
+			invalid("%s: must be a positive duration, such as 90s", name)
+		}
+	}
+	if c.Timeouts.InvalidationInterval > c.Timeouts.Invalidation {
+		invalid("timeouts.invalidationInterval: must not exceed timeouts.invalidation")
+	}
+	for name, jobs := range map[string]Jobs{"explorations": c.Explorations, "invalidations": c.Invalidations} {
+		if jobs.Workers < 1 {
+			invalid("%s.workers: must be at least 1", name)
+		}
+		if jobs.QueueSize < 1 {
+			invalid("%s.queueSize: must be at least 1", name)
+		}
+	}
+
+	for _, e := range c.Explorers {
+		if !contains(explorer.Explorers, e) {
+			invalid("explorers: unknown explorer %q, expected one of %v", e, explorer.Explorers)
+		}
+	}
+	if contains(c.Explorers, explorer.ExplorerBucketPolicies) && !contains(c.Explorers, explorer.ExplorerCloudFront) {
+		invalid("explorers: %s requires %s", explorer.ExplorerBucketPolicies, explorer.ExplorerCloudFront)
+	}
+	if _, err := insights.Default.Select(c.Insights.Enabled, c.Insights.Disabled); err != nil {
+		invalid("insights: %v", err)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Explorer returns the configuration of an exploration
+func (c Config) Explorer() explorer.Config {
+	e := explorer.DefaultConfig()
+	e.Region = c.Aws.Region
+	e.DnsServers = append([]string(nil), c.Resolvers...)
+	e.IndexFilePath = c.IndexFilePath
+	e.IpRangesFilePath = c.IpRanges.FilePath
+	e.IpRangesUrl = c.IpRanges.Url
+	e.IpRangesRefreshInterval = time.Duration(c.IpRanges.RefreshInterval)
+	e.Roles = accounts.Roles{}
+	for key, chain := range c.Aws.Roles {
+		e.Roles[key] = chain
+	}
+	e.Timeout = time.Duration(c.Timeouts.Exploration)
+	e.TraceServer = c.TraceServer
+	e.Explorers = append([]string(nil), c.Explorers...)
+
+	// An empty list runs every explorer or insight, so turning all insights
+	// off turns off the insights explorer.
+	registry, err := insights.Default.Select(c.Insights.Enabled, c.Insights.Disabled)
+	if err == nil && (len(c.Insights.Enabled) > 0 || len(c.Insights.Disabled) > 0) {
+		e.Insights = nil
+		for _, insight := range registry.Insights() {
+			e.Insights = append(e.Insights, insight.Id())
+		}
+		if len(e.Insights) == 0 {
+			explorers := e.Explorers
+			if len(explorers) == 0 {
+				explorers = explorer.DefaultExplorers
+			}
+			// Not nil, which would run the default explorers
+			e.Explorers = []string{}
+			for _, name := range explorers {
+				if name != explorer.ExplorerInsights {
+					e.Explorers = append(e.Explorers, name)
+				}
+			}
+		}
+	}
+	return e
+}
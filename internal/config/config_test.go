@@ -0,0 +1,187 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unfor19/columbus-app/internal/explorer"
+	"github.com/unfor19/columbus-app/internal/insights"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadExample(t *testing.T) {
+	c, err := Load("../../columbus.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Resolvers, []string{"1.1.1.1:53", "8.8.8.8:53"}) {
+		t.Errorf("unexpected resolvers %v", c.Resolvers)
+	}
+	if chain := c.Aws.Roles["222222222222"]; len(chain) != 1 {
+		t.Errorf("unexpected account roles %v", c.Aws.Roles)
+	}
+	e := c.Explorer()
+	if e.Timeout != 5*time.Minute || e.DnsServers[1] != "8.8.8.8:53" {
+		t.Errorf("unexpected explorer config %+v", e)
+	}
+	for _, id := range e.Insights {
+		if id == "cloudfront-waf" {
+			t.Error("expected cloudfront-waf to be disabled")
+		}
+	}
+	if len(e.Insights) == 0 {
+		t.Error("expected the remaining insights to be enabled")
+	}
+}
+
+func TestLoadJSONWithEnv(t *testing.T) {
+	path := writeFile(t, "columbus.json", `{"listen": "127.0.0.1:9090", "aws": {"region": "us-east-1"}, "timeouts": {"exploration": "90s"}}`)
+	os.Setenv("COLUMBUS_AWS_REGION", "ap-southeast-2")
+	defer os.Unsetenv("COLUMBUS_AWS_REGION")
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Listen != "127.0.0.1:9090" || c.Timeouts.Exploration != Duration(90*time.Second) {
+		t.Errorf("unexpected config %+v", c)
+	}
+	if c.Aws.Region != "ap-southeast-2" {
+		t.Errorf("expected the environment to override the file, got %s", c.Aws.Region)
+	}
+	if c.Explorations.Workers != 4 {
+		t.Errorf("expected the default workers, got %d", c.Explorations.Workers)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := Load(writeFile(t, "typo.yaml", "listenn: :8080\n")); err == nil || !strings.Contains(err.Error(), "listenn") {
+		t.Errorf("expected an unknown field error, got %v", err)
+	}
+
+	path := writeFile(t, "invalid.yaml", `
+listen: "8080"
+resolvers: [dns.google]
+aws:
+  region: europe
+  roles:
+    lambda: [arn:aws:iam::111111111111:role/columbus]
+timeouts:
+  exploration: 0s
+explorations:
+  workers: 0
+invalidations:
+  queueSize: 0
+explorers: [cloudfront, ec2]
+insights:
+  disabled: [no-such-insight]
+`)
+	_, err := Load(path)
+	v, ok := err.(ValidationError)
+	if !ok {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	for _, setting := range []string{"listen", "resolvers", "aws.region", "aws.roles", "timeouts.exploration", "explorations.workers", "invalidations.queueSize", "explorers", "insights"} {
+		found := false
+		for _, message := range v {
+			if strings.HasPrefix(message, setting+":") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected an error for %s, got:\n%v", setting, err)
+		}
+	}
+}
+
+func TestExplorerWithoutInsights(t *testing.T) {
+	c := Default()
+	for _, insight := range insights.Default.Insights() {
+		c.Insights.Disabled = append(c.Insights.Disabled, insight.Id())
+	}
+	e := c.Explorer()
+	for _, name := range e.Explorers {
+		if name == explorer.ExplorerInsights {
+			t.Errorf("expected the insights explorer to be off, got %v", e.Explorers)
+		}
+	}
+
+	// Without the insights explorer no explorer is left to run
+	c.Explorers = []string{explorer.ExplorerInsights}
+	if e := c.Explorer(); e.Explorers == nil || len(e.Explorers) != 0 {
+		t.Errorf("expected no explorers, got %#v", e.Explorers)
+	}
+}
+
+func TestDelegationIsOptIn(t *testing.T) {
+	if contains(Default().Explorer().Explorers, explorer.ExplorerDelegation) {
+		t.Error("expected the delegation explorer to be off by default")
+	}
+	os.Setenv("COLUMBUS_EXPLORERS", "cloudfront,route53,delegation")
+	defer os.Unsetenv("COLUMBUS_EXPLORERS")
+	c, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(c.Explorer().Explorers, explorer.ExplorerDelegation) {
+		t.Errorf("expected the delegation explorer to be on, got %v", c.Explorer().Explorers)
+	}
+}
+
+func TestLoadEnvOverridesFile(t *testing.T) {
+	path := writeFile(t, "columbus.yaml", "aws:\n  region: us-east-1\nexplorations:\n  queueSize: 10\n")
+	for name, value := range map[string]string{
+		"AWS_REGION":                       "ap-southeast-2",
+		"COLUMBUS_QUEUE_SIZE":              "20",
+		"COLUMBUS_RETENTION":               "2h",
+		"COLUMBUS_INVALIDATION_INTERVAL":   "30s",
+		"COLUMBUS_INVALIDATION_QUEUE_SIZE": "5",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Aws.Region != "us-east-1" {
+		t.Errorf("expected the file to override AWS_REGION, got %s", c.Aws.Region)
+	}
+	if c.Explorations.QueueSize != 20 || c.Explorations.Retention != Duration(2*time.Hour) {
+		t.Errorf("unexpected explorations %+v", c.Explorations)
+	}
+	if c.Timeouts.InvalidationInterval != Duration(30*time.Second) || c.Invalidations.QueueSize != 5 {
+		t.Errorf("unexpected invalidations %+v %+v", c.Timeouts, c.Invalidations)
+	}
+
+	// AWS_REGION replaces the default region of a file without one
+	if c, err := Load(writeFile(t, "no-region.yaml", "listen: :8080\n")); err != nil || c.Aws.Region != "ap-southeast-2" {
+		t.Errorf("expected AWS_REGION to replace the default region, got %s %v", c.Aws.Region, err)
+	}
+
+	os.Setenv("COLUMBUS_AWS_REGION", "eu-central-1")
+	defer os.Unsetenv("COLUMBUS_AWS_REGION")
+	if c, err := Load(path); err != nil || c.Aws.Region != "eu-central-1" {
+		t.Errorf("expected COLUMBUS_AWS_REGION to override the file, got %s %v", c.Aws.Region, err)
+	}
+}
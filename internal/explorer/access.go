@@ -0,0 +1,82 @@
+package explorer
+
+import (
+	"log"
+	"strings"
+
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+	"github.com/unfor19/columbus-app/internal/aws/service/iam"
+)
+
+// accessRequests lists who may read the object - anonymous users, the
+// distribution through the origin's OAI or OAC, and the configured principals.
+func accessRequests(o ccloudfront.CloudFrontOrigin, distributionArn string, principals []string) []iam.Request {
+	resource := "arn:aws:s3:::" + o.OriginName + "/" + o.OriginObjectKey
+	secureTransport := map[string]iam.Value{"aws:SecureTransport": {"true"}}
+	request := func(principal iam.RequestPrincipal, context map[string]iam.Value) iam.Request {
+		return iam.Request{Principal: principal, Action: "s3:GetObject", Resource: resource, Context: context}
+	}
+
+	requests := []iam.Request{request(iam.AnonymousPrincipal, secureTransport)}
+	if o.OriginAccessIdentity != "" {
+		parts := strings.Split(o.OriginAccessIdentity, "/")
+		requests = append(requests, request(iam.AwsPrincipal(iam.OriginAccessIdentityArn(parts[len(parts)-1])), secureTransport))
+	}
+	if arnParts := strings.Split(distributionArn, ":"); o.OriginAccessControlId != "" && len(arnParts) > 4 {
+		requests = append(requests, request(iam.ServicePrincipal(iam.CloudFrontServicePrincipal), map[string]iam.Value{
+			"aws:SecureTransport": {"true"},
+			"aws:SourceArn":       {distributionArn},
+			"aws:SourceAccount":   {arnParts[4]},
+		}))
+	}
+	for _, arn := range principals {
+		requests = append(requests, request(iam.AwsPrincipal(arn), secureTransport))
+	}
+	return requests
+}
+
+// permissiveness ranks the decisions from the least to the most permissive
+var permissiveness = map[iam.Decision]int{
+	iam.DecisionExplicitDeny: 0,
+	iam.DecisionImplicitDeny: 1,
+	iam.DecisionAllow:        2,
+}
+
+// evaluate applies the policy to r. Anonymous users may reach the origin over
+// HTTPS or plain HTTP, as the direct origin probe does, so they are evaluated
+// for both values of aws:SecureTransport and the more permissive result wins.
+func evaluate(policy iam.PolicyDocument, r iam.Request) iam.Evaluation {
+	evaluation := policy.Evaluate(r)
+	if r.Principal != iam.AnonymousPrincipal {
+		return evaluation
+	}
+	insecure := r
+	insecure.Context = map[string]iam.Value{}
+	for key, value := range r.Context {
+		insecure.Context[key] = value
+	}
+	insecure.Context["aws:SecureTransport"] = iam.Value{"false"}
+	if e := policy.Evaluate(insecure); permissiveness[e.Decision] > permissiveness[evaluation.Decision] {
+		return e
+	}
+	return evaluation
+}
+
+// evaluateBucketPolicies answers who can read the object behind the request
+// URL according to each S3 origin's bucket policy.
+func (e *Explorer) evaluateBucketPolicies(requestUrl string) error {
+	for i, o := range e.Mapping.CloudFrontOrigins {
+		if !strings.HasPrefix(o.OriginType, "s3") || !o.OriginResourceExists {
+			continue
+		}
+		o.OriginObjectKey = o.ObjectKey(e.Mapping.TargetDomain.RequestPath, e.Mapping.Distribution.DefaultRootObject, e.config.IndexFilePath)
+		o.OriginObjectAccess = nil
+		for _, r := range accessRequests(o, e.Mapping.Distribution.Arn, e.config.Principals) {
+			evaluation := evaluate(o.OriginBucketPolicy, r)
+			log.Println(i, "Bucket policy:", evaluation.Principal, evaluation.Action, evaluation.Resource, evaluation.Decision)
+			o.OriginObjectAccess = append(o.OriginObjectAccess, evaluation)
+		}
+		e.Mapping.CloudFrontOrigins[i] = o
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package explorer
+
+import (
+	"testing"
+
+	"github.com/unfor19/columbus-app/internal/aws/service/iam"
+)
+
+func TestEvaluateAnonymousTransport(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		decision iam.Decision
+	}{
+		{"https only", `{"Version": "2012-10-17", "Statement": [
+			{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"},
+			{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket/*", "Condition": {"Bool": {"aws:SecureTransport": "false"}}}
+		]}`, iam.DecisionAllow},
+		{"http only", `{"Version": "2012-10-17", "Statement": [
+			{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*", "Condition": {"Bool": {"aws:SecureTransport": "false"}}}
+		]}`, iam.DecisionAllow},
+		{"deny all", `{"Version": "2012-10-17", "Statement": [
+			{"Effect": "Deny", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}
+		]}`, iam.DecisionExplicitDeny},
+	}
+	for _, tt := range tests {
+		policy, err := iam.ParsePolicyDocument(tt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := iam.Request{
+			Principal: iam.AnonymousPrincipal,
+			Action:    "s3:GetObject",
+			Resource:  "arn:aws:s3:::bucket/index.html",
+			Context:   map[string]iam.Value{"aws:SecureTransport": {"true"}},
+		}
+		if e := evaluate(policy, r); e.Decision != tt.decision {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.decision, e.Decision)
+		}
+		if r.Context["aws:SecureTransport"][0] != "true" {
+			t.Errorf("%s: the request context was modified", tt.name)
+		}
+	}
+}
@@ -0,0 +1,475 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/unfor19/columbus-app/internal/aws/accounts"
+	awsnetwork "github.com/unfor19/columbus-app/internal/aws/network"
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+	croute53 "github.com/unfor19/columbus-app/internal/aws/service/route53"
+	"github.com/unfor19/columbus-app/internal/insights"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
+	"github.com/unfor19/columbus-app/pkg/traffic"
+)
+
+var (
+	ErrInvalidUrl = errors.New("invalid request URL")
+	ErrNoTargetIp = errors.New("failed to resolve target IP address")
+)
+
+// Result is the response of an exploration, the mapping fields are kept at
+// the top level so existing consumers of the raw mapping keep working.
+type Result struct {
+	ccloudfront.AwsMapping
+	Findings []insights.Finding
+}
+
+// Optional explorers, the stages that can be turned off
+const (
+	ExplorerCloudFront     = "cloudfront"
+	ExplorerBucketPolicies = "bucket-policies"
+	ExplorerRoute53        = "route53"
+	ExplorerDelegation     = "delegation"
+	ExplorerInsights       = "insights"
+)
+
+var Explorers = []string{ExplorerCloudFront, ExplorerBucketPolicies, ExplorerRoute53, ExplorerDelegation, ExplorerInsights}
+
+// DefaultExplorers run when none are configured. The delegation trace takes
+// several round trips from the root servers, so it runs only when enabled.
+var DefaultExplorers = []string{ExplorerCloudFront, ExplorerBucketPolicies, ExplorerRoute53, ExplorerInsights}
+
+type Config struct {
+	// Region is used for the AWS clients, S3 clients use each bucket's region
+	Region string
+	// DnsServers are tried in order until one resolves the target domain
+	DnsServers       []string
+	IndexFilePath    string
+	IpRangesFilePath string
+	IpRangesUrl      string
+	// IpRangesRefreshInterval is how long the ip ranges are used before
+	// checking for newer ones
+	IpRangesRefreshInterval time.Duration
+	// Principals are IAM ARNs whose access to the requested object is
+	// evaluated against the bucket policies, in addition to anonymous users
+	// and CloudFront.
+	Principals []string
+	// Roles are assumed to explore resources that live in other accounts
+	Roles accounts.Roles
+	// Explorers are the optional explorers to run, DefaultExplorers when nil,
+	// none of them when empty
+	Explorers []string
+	// Insights are the ids of the insights to evaluate, all of them when empty
+	Insights []string
+	// Timeout limits the whole exploration when set
+	Timeout time.Duration
+	// TraceServer is where the delegation trace starts, the root servers
+	// when empty
+	TraceServer string
+}
+
+// DefaultConfig returns the built-in defaults, the config package applies
+// the configuration file and environment variables on top of them.
+func DefaultConfig() Config {
+	return Config{
+		Region:           "eu-west-1",
+		DnsServers:       []string{"1.1.1.1:53"}, // Using Cloudflare's DNS Server
+		IndexFilePath:    "index.html",
+		IpRangesFilePath: ".ip-ranges.json",
+		IpRangesUrl:      "https://ip-ranges.amazonaws.com/ip-ranges.json",
+		// AWS publishes changes a few times a week
+		IpRangesRefreshInterval: 12 * time.Hour,
+	}
+}
+
+func (c Config) explores(explorer string) bool {
+	explorers := c.Explorers
+	if explorers == nil {
+		explorers = DefaultExplorers
+	}
+	for _, e := range explorers {
+		if e == explorer {
+			return true
+		}
+	}
+	return false
+}
+
+// Explorer maps a single request URL to its AWS resources. It carries its own
+// configuration and result, so each request must use a new Explorer.
+type Explorer struct {
+	ctx      context.Context
+	config   Config
+	accounts *accounts.Accounts
+	progress func(stage string)
+	insights *insights.Registry
+	// awsConfig replaces the SDK's default configuration when it is set
+	awsConfig *aws.Config
+	// distributions are the distributions of the CloudFront account
+	distributions []cftypes.DistributionSummary
+	// distributionConfig is the configuration of the account that the
+	// selected distribution was found in
+	distributionConfig *aws.Config
+	Mapping            ccloudfront.AwsMapping
+	Findings           []insights.Finding
+}
+
+func New(ctx context.Context, config Config) *Explorer {
+	return &Explorer{
+		ctx:      ctx,
+		config:   config,
+		progress: func(stage string) {},
+		insights: insights.Default,
+	}
+}
+
+// OnProgress sets a callback that is invoked when a pipeline stage starts.
+func (e *Explorer) OnProgress(progress func(stage string)) {
+	e.progress = progress
+}
+
+func (e *Explorer) stage(name string) error {
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+	log.Println("Stage:", name)
+	e.progress(name)
+	return nil
+}
+
+// UseInsights replaces the default insights registry.
+func (e *Explorer) UseInsights(registry *insights.Registry) {
+	e.insights = registry
+}
+
+// UseAwsConfig replaces the SDK's default configuration, the roles are still
+// assumed from it.
+func (e *Explorer) UseAwsConfig(cfg aws.Config) {
+	e.awsConfig = &cfg
+}
+
+// DistributionAccounts returns the accounts of the exploration and the
+// configuration of the account that the selected distribution was found in,
+// ok is false when no distribution was selected.
+func (e *Explorer) DistributionAccounts() (a *accounts.Accounts, cfg aws.Config, ok bool) {
+	if e.distributionConfig == nil {
+		return e.accounts, aws.Config{}, false
+	}
+	return e.accounts, *e.distributionConfig, true
+}
+
+func (e *Explorer) result() Result {
+	return Result{AwsMapping: e.Mapping, Findings: e.Findings}
+}
+
+func (e *Explorer) Explore(requestUrl string) (Result, error) {
+	log.Println("Request URL:", requestUrl)
+	if e.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		e.ctx, cancel = context.WithTimeout(e.ctx, e.config.Timeout)
+		defer cancel()
+	}
+	if len(e.config.Insights) > 0 {
+		registry, err := e.insights.Select(e.config.Insights, nil)
+		if err != nil {
+			return e.result(), err
+		}
+		e.insights = registry
+	}
+	stages := []struct {
+		name     string
+		explorer string
+		run      func(requestUrl string) error
+	}{
+		{"Resolving target domain", "", e.resolveTargetDomain},
+		{"Finding target AWS service", "", e.findTargetService},
+		{"Requesting target URL", "", e.requestTargetUrl},
+		{"Loading AWS configuration", "", e.loadAwsConfig},
+		{"Searching CloudFront distributions", ExplorerCloudFront, e.exploreCloudFront},
+		{"Routing the request path", ExplorerCloudFront, e.routeRequestPath},
+		{"Evaluating bucket policies", ExplorerBucketPolicies, e.evaluateBucketPolicies},
+		{"Searching Route53 records", ExplorerRoute53, e.exploreRoute53},
+		{"Matching DNS records to CloudFront", ExplorerCloudFront, e.matchDnsTargets},
+		{"Tracing DNS delegation", ExplorerDelegation, e.traceDelegation},
+		{"Evaluating insights", ExplorerInsights, e.evaluateInsights},
+	}
+	for _, s := range stages {
+		if s.explorer != "" && !e.config.explores(s.explorer) {
+			log.Println("Skipping stage:", s.name)
+			continue
+		}
+		if err := e.stage(s.name); err != nil {
+			return e.result(), err
+		}
+		if err := s.run(requestUrl); err != nil {
+			return e.result(), err
+		}
+	}
+	return e.result(), nil
+}
+
+func (e *Explorer) resolveTargetDomain(requestUrl string) error {
+	u, err := cdns.ParseRequestUrl(requestUrl)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("%w %q", ErrInvalidUrl, requestUrl)
+	}
+	domainName := strings.ToLower(u.Hostname())
+	e.Mapping.TargetDomain.DomainName = domainName
+	e.Mapping.TargetDomain.IndexFilePath = e.config.IndexFilePath
+	e.Mapping.TargetDomain.RequestUrl = u.String()
+	e.Mapping.TargetDomain.RequestPath = u.Path
+	if u.Path == "" {
+		e.Mapping.TargetDomain.RequestPath = "/"
+	}
+	log.Println("Request Domain Name:", domainName)
+	registeredDomainName := cdns.GetRegisteredDomainName(domainName)
+	e.Mapping.TargetDomain.RegisteredName = registeredDomainName
+	log.Println("Registered Domain Name:", registeredDomainName)
+	// Another resolver is tried only when one fails, a name that does not
+	// exist does not exist for all of them
+	var resolution cdns.Resolution
+	for _, dnsServer := range e.config.DnsServers {
+		var err error
+		resolution, err = cdns.Resolve(e.ctx, domainName, dnsServer)
+		if err == nil && resolution.Rcode != "SERVFAIL" {
+			break
+		}
+		log.Println("Failed to resolve", domainName, "at", dnsServer, err, resolution.Rcode)
+	}
+	e.Mapping.TargetDomain.Resolution = resolution
+	for _, record := range append(resolution.CnameChain, resolution.Addresses...) {
+		log.Println("DNS record:", record)
+	}
+	e.Mapping.TargetDomain.NsLookup = nil
+	for _, record := range resolution.Addresses {
+		e.Mapping.TargetDomain.NsLookup = append(e.Mapping.TargetDomain.NsLookup, resolution.Name+" IN "+record.Type+" "+record.Value+"\n")
+	}
+	ips := resolution.IPs()
+	if len(ips) == 0 {
+		return ErrNoTargetIp
+	}
+	e.Mapping.TargetDomain.TargetIpAddress = ips[0].String()
+	log.Println("Target IP Address:", ips[0])
+	return nil
+}
+
+// Find Target Service - CLOUDFRONT, S3, API_GATEWAY, EC2
+func (e *Explorer) findTargetService(requestUrl string) error {
+	ipRanges := awsnetwork.SharedIpRangesManager(e.config.IpRangesFilePath, e.config.IpRangesUrl, e.config.IpRangesRefreshInterval)
+	index, err := ipRanges.Index(e.ctx)
+	if err != nil {
+		return err
+	}
+	targetIpAddress := e.Mapping.TargetDomain.TargetIpAddress
+	if ip := net.ParseIP(targetIpAddress); ip != nil {
+		e.Mapping.TargetDomain.TargetIpRanges = index.Lookup(ip)
+	}
+	targetAwsService := index.TargetService(targetIpAddress)
+	e.Mapping.TargetDomain.TargetService = targetAwsService
+	log.Println("Target AWS Service:", targetAwsService)
+	return nil
+}
+
+func (e *Explorer) requestTargetUrl(requestUrl string) error {
+	requestUrlResponse, err := traffic.GetRequestUrlResponse(e.ctx, requestUrl)
+	if err != nil {
+		return err
+	}
+	log.Println("Target Url Response:")
+	e.Mapping.TargetDomain.UrlResponse.StatusCode = requestUrlResponse.StatusCode
+
+	log.Println(requestUrlResponse.StatusCode, requestUrlResponse.Header)
+	for name, values := range requestUrlResponse.Header {
+		for _, value := range values {
+			e.Mapping.TargetDomain.UrlResponse.Headers = append(e.Mapping.TargetDomain.UrlResponse.Headers, traffic.HttpHeader{
+				Name:  name,
+				Value: value,
+			})
+		}
+	}
+	if requestUrlResponse.Header.Get("Server") == "AmazonS3" {
+		requestUrlResponseEtag := traffic.GetResponseETag(requestUrlResponse)
+		if requestUrlResponseEtag != "" {
+			log.Println("Request Url Response ETag:", requestUrlResponseEtag)
+			e.Mapping.TargetDomain.EtagResponse = requestUrlResponseEtag
+		}
+	}
+	return nil
+}
+
+// LoadAwsConfig uses the SDK's default configuration, loading additional config
+// and credentials values from the environment variables, shared
+// credentials, and shared configuration files
+func (c Config) LoadAwsConfig(ctx context.Context) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(c.Region),
+	)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	return cfg, nil
+}
+
+func (e *Explorer) loadAwsConfig(requestUrl string) error {
+	if e.awsConfig != nil {
+		e.accounts = accounts.New(*e.awsConfig, e.config.Roles)
+		return nil
+	}
+	cfg, err := e.config.LoadAwsConfig(e.ctx)
+	if err != nil {
+		return err
+	}
+	e.accounts = accounts.New(cfg, e.config.Roles)
+	return nil
+}
+
+// Handle AWS CloudFront Distributions and their Origins
+func (e *Explorer) exploreCloudFront(requestUrl string) error {
+	domainName := e.Mapping.TargetDomain.DomainName
+	// The distributions may live in any of the configured accounts
+	var listErr error
+	configs := map[string]aws.Config{}
+	e.distributions = nil
+	for _, cfg := range e.accounts.Candidates(accounts.ServiceCloudFront) {
+		distributions, err := ccloudfront.ListCloudfrontDistributions(e.ctx, cfg)
+		if err != nil {
+			log.Println(err)
+			listErr = err
+			continue
+		}
+		for _, distribution := range distributions {
+			if _, ok := configs[aws.ToString(distribution.Id)]; ok {
+				continue
+			}
+			configs[aws.ToString(distribution.Id)] = cfg
+			e.distributions = append(e.distributions, distribution)
+		}
+	}
+	if len(configs) == 0 && listErr != nil {
+		return listErr
+	}
+	targetAwsDistribution, matches := ccloudfront.SelectDistribution(e.distributions, domainName, e.Mapping.TargetDomain.Resolution)
+	e.Mapping.TargetDomain.DistributionMatches = matches
+	if targetAwsDistribution.Id == nil {
+		// The DNS targets and insights explain why no distribution serves it
+		log.Println("No CloudFront distribution found for", domainName)
+		return nil
+	}
+	log.Println("Target CloudFront Distribution:", *targetAwsDistribution.Id)
+	cfg := configs[*targetAwsDistribution.Id]
+	e.distributionConfig = &cfg
+	e.Mapping.Distribution = ccloudfront.NewCloudFrontDistribution(targetAwsDistribution)
+	if aws.ToString(targetAwsDistribution.WebACLId) != "" {
+		log.Println("Target CloudFront Distribution WAF Id:", *targetAwsDistribution.WebACLId)
+		e.Mapping.TargetDomain.WafId = *targetAwsDistribution.WebACLId
+	} else {
+		log.Println("Target CloudFront Distribution WAF Id:", "none")
+		e.Mapping.TargetDomain.WafId = "none"
+	}
+
+	log.Println("Target Distribution Status:", aws.ToString(targetAwsDistribution.Status))
+	defaultRootObject, err := ccloudfront.GetDefaultRootObject(e.ctx, cfg, *targetAwsDistribution.Id)
+	if err != nil {
+		log.Println(err)
+	}
+	e.Mapping.Distribution.DefaultRootObject = defaultRootObject
+	if err := e.stage("Inspecting CloudFront origins"); err != nil {
+		return err
+	}
+	targetOrigins := ccloudfront.GetAwsCloudfrontOrigins(e.ctx, cfg, e.accounts.Bucket, targetAwsDistribution, defaultRootObject, e.config.IndexFilePath)
+	e.Mapping.CloudFrontOrigins = ccloudfront.SetAwsCloudFrontOrigins(e.ctx, cfg, targetOrigins)
+	return nil
+}
+
+// routeRequestPath finds the cache behavior and origin of the distribution
+// that serve the path of the request URL
+func (e *Explorer) routeRequestPath(requestUrl string) error {
+	for _, distribution := range e.distributions {
+		if aws.ToString(distribution.Id) != e.Mapping.Distribution.Id {
+			continue
+		}
+		route := ccloudfront.GetRoute(distribution, e.Mapping.TargetDomain.RequestPath, e.Mapping.Distribution.DefaultRootObject, e.config.IndexFilePath)
+		log.Println("Request path", route.Path, "matches", route.PathPattern, "routed to origin", route.OriginId, "key", route.ObjectKey)
+		e.Mapping.Route = &route
+	}
+	return nil
+}
+
+func (e *Explorer) exploreRoute53(requestUrl string) error {
+	domainName := e.Mapping.TargetDomain.DomainName
+	// The hosted zones may live in any of the configured accounts
+	for _, cfg := range e.accounts.Candidates(accounts.ServiceRoute53) {
+		zones, err := croute53.GetZoneRecords(e.ctx, cfg, domainName)
+		if err != nil {
+			log.Println(err)
+		}
+		if len(zones) == 0 {
+			continue
+		}
+		for _, zone := range zones {
+			hostedZone := zone.Zone
+			log.Println("Route53 hosted zone:", aws.ToString(hostedZone.Name), aws.ToString(hostedZone.Id), "private:", croute53.IsPrivate(hostedZone))
+			e.Mapping.TargetDomain.Route53Records = append(e.Mapping.TargetDomain.Route53Records, zone.Records...)
+			if croute53.IsPrivate(hostedZone) || e.Mapping.TargetDomain.Route53Zone != "" {
+				continue
+			}
+			e.Mapping.TargetDomain.Route53Zone = aws.ToString(hostedZone.Name)
+			nameServers, err := croute53.GetDelegationSet(e.ctx, cfg, hostedZone.Id)
+			if err != nil {
+				log.Println(err)
+			}
+			e.Mapping.TargetDomain.Route53NameServers = nameServers
+		}
+		break
+	}
+	route53Record := "none"
+	for _, record := range e.Mapping.TargetDomain.Route53Records {
+		log.Println("Route53 record:", record.Name, record.Type, record.RoutingPolicy, record.SetIdentifier)
+		route53Record = record.Name
+	}
+	e.Mapping.TargetDomain.Route53Record = route53Record
+	return nil
+}
+
+// matchDnsTargets finds the distributions that the Route53 records and the
+// CNAME chain of the domain point to
+func (e *Explorer) matchDnsTargets(requestUrl string) error {
+	target := &e.Mapping.TargetDomain
+	target.DnsTargets = ccloudfront.FindDnsTargets(target.Route53Records, target.Resolution, e.distributions)
+	for _, t := range target.DnsTargets {
+		log.Println("DNS target:", t.Source, t.Record, t.DomainName, t.DistributionId)
+	}
+	return nil
+}
+
+// traceDelegation follows the delegation of the domain from the root, a
+// network that blocks iterative queries does not fail the exploration
+func (e *Explorer) traceDelegation(requestUrl string) error {
+	resolver := ""
+	if len(e.config.DnsServers) > 0 {
+		resolver = e.config.DnsServers[0]
+	}
+	trace, err := cdns.TraceDelegation(e.ctx, e.Mapping.TargetDomain.DomainName, e.config.TraceServer, resolver)
+	if err != nil {
+		log.Println("Failed to trace the DNS delegation:", err)
+		return nil
+	}
+	log.Println("Delegated zone:", trace.Zone, trace.NameServers)
+	e.Mapping.TargetDomain.Delegation = &trace
+	return nil
+}
+
+func (e *Explorer) evaluateInsights(requestUrl string) error {
+	e.Findings = e.insights.Evaluate(e.Mapping)
+	log.Println("Found", len(e.Findings), "insights")
+	return nil
+}
@@ -0,0 +1,240 @@
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/miekg/dns"
+)
+
+// testSites is how many sites are explored at once, site<n>.example.com is
+// served by the distribution E<n> from the bucket site<n>
+const testSites = 8
+
+const testIpRanges = `{
+  "syncToken": "1",
+  "createDate": "2022-08-01-12-00-00",
+  "prefixes": [
+    {"ip_prefix": "13.224.0.0/14", "region": "GLOBAL", "service": "AMAZON", "network_border_group": "GLOBAL"},
+    {"ip_prefix": "13.224.0.0/14", "region": "GLOBAL", "service": "CLOUDFRONT", "network_border_group": "GLOBAL"}
+  ]
+}`
+
+func testDistribution(n int) string {
+	return fmt.Sprintf(`<DistributionSummary>
+  <Id>E%[1]d</Id>
+  <ARN>arn:aws:cloudfront::111111111111:distribution/E%[1]d</ARN>
+  <Status>Deployed</Status>
+  <LastModifiedTime>2022-08-01T12:00:00Z</LastModifiedTime>
+  <DomainName>d%[1]d.cloudfront.net</DomainName>
+  <Aliases><Quantity>1</Quantity><Items><CNAME>site%[1]d.example.com</CNAME></Items></Aliases>
+  <Origins><Quantity>1</Quantity><Items><Origin>
+    <Id>s3-site%[1]d</Id>
+    <DomainName>site%[1]d.s3.eu-west-1.amazonaws.com</DomainName>
+    <OriginPath></OriginPath>
+    <S3OriginConfig><OriginAccessIdentity>origin-access-identity/cloudfront/EOAI%[1]d</OriginAccessIdentity></S3OriginConfig>
+  </Origin></Items></Origins>
+  <DefaultCacheBehavior><TargetOriginId>s3-site%[1]d</TargetOriginId><ViewerProtocolPolicy>redirect-to-https</ViewerProtocolPolicy></DefaultCacheBehavior>
+  <CacheBehaviors><Quantity>0</Quantity></CacheBehaviors>
+  <WebACLId></WebACLId>
+  <Enabled>true</Enabled>
+</DistributionSummary>`, n)
+}
+
+func testBucketPolicy(bucket string) string {
+	return fmt.Sprintf(`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity EOAI%s"}, "Action": "s3:GetObject", "Resource": "arn:aws:s3:::%s/*"}]}`,
+		strings.TrimPrefix(bucket, "site"), bucket)
+}
+
+func has(query url.Values, key string) bool {
+	_, ok := query[key]
+	return ok
+}
+
+// serveAws answers the CloudFront, Route53 and path style S3 requests of an
+// exploration
+func serveAws(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "2020-05-31/distribution":
+		var items strings.Builder
+		for n := 1; n <= testSites; n++ {
+			items.WriteString(testDistribution(n))
+		}
+		fmt.Fprintf(w, `<DistributionList><Marker></Marker><MaxItems>%[1]d</MaxItems><IsTruncated>false</IsTruncated><Quantity>%[1]d</Quantity><Items>%[2]s</Items></DistributionList>`, testSites, items.String())
+	case strings.HasPrefix(path, "2020-05-31/distribution/") && strings.HasSuffix(path, "/config"):
+		fmt.Fprint(w, `<DistributionConfig><DefaultRootObject>index.html</DefaultRootObject></DistributionConfig>`)
+	case strings.HasPrefix(path, "2013-04-01/hostedzonesbyname"):
+		fmt.Fprint(w, `<ListHostedZonesByNameResponse><HostedZones></HostedZones><IsTruncated>false</IsTruncated><MaxItems>100</MaxItems></ListHostedZonesByNameResponse>`)
+	case strings.HasPrefix(path, "site"):
+		bucket := strings.Split(path, "/")[0]
+		query := r.URL.Query()
+		switch {
+		case strings.Contains(path, "/"):
+			w.Header().Set("ETag", `"etag-`+bucket+`"`)
+		case has(query, "policy"):
+			fmt.Fprint(w, testBucketPolicy(bucket))
+		case has(query, "policyStatus"):
+			fmt.Fprint(w, `<PolicyStatus><IsPublic>false</IsPublic></PolicyStatus>`)
+		case has(query, "website"):
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchWebsiteConfiguration</Code><Message>The specified bucket does not have a website configuration</Message></Error>`)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// serveSites answers the requests to the sites, as CloudFront does, and to
+// the buckets' REST endpoints, which deny anonymous users
+func serveSites(w http.ResponseWriter, r *http.Request) {
+	host := strings.Split(r.Host, ".")[0]
+	w.Header().Set("Server", "AmazonS3")
+	if strings.Contains(r.Host, ".s3.") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.Header().Set("ETag", `"etag-`+host+`"`)
+	fmt.Fprint(w, "<html></html>")
+}
+
+// startTestResolver answers site<n>.example.com with an address of the
+// CloudFront ip ranges
+func startTestResolver(t *testing.T) string {
+	t.Helper()
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		var n int
+		if _, err := fmt.Sscanf(q.Name, "site%d.example.com.", &n); err != nil {
+			m.Rcode = dns.RcodeNameError
+		} else if q.Qtype == dns.TypeA {
+			rr, _ := dns.NewRR(fmt.Sprintf("%s 60 IN A 13.224.0.%d", q.Name, n))
+			m.Answer = append(m.Answer, rr)
+		}
+		w.WriteMsg(m)
+	})
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: handler}
+	started := make(chan struct{})
+	server.NotifyStartedFunc = func() { close(started) }
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+// routeHttpTo sends the requests of the default transport to other than local
+// servers, the requests to the sites and the origin probes, to address
+func routeHttpTo(t *testing.T, address string) {
+	t.Helper()
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = &http.Transport{
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			var d net.Dialer
+			if host, _, _ := net.SplitHostPort(addr); host != "127.0.0.1" {
+				addr = address
+			}
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	t.Cleanup(func() { http.DefaultTransport = defaultTransport })
+}
+
+// TestExploreConcurrently runs explorations of different sites at once, run
+// it with -race. Each exploration must only see its own site's resources.
+func TestExploreConcurrently(t *testing.T) {
+	awsServer := httptest.NewServer(http.HandlerFunc(serveAws))
+	defer awsServer.Close()
+	sitesServer := httptest.NewServer(http.HandlerFunc(serveSites))
+	defer sitesServer.Close()
+	ipRangesServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testIpRanges)
+	}))
+	defer ipRangesServer.Close()
+	routeHttpTo(t, sitesServer.Listener.Addr().String())
+
+	config := DefaultConfig()
+	config.DnsServers = []string{startTestResolver(t)}
+	config.IpRangesFilePath = filepath.Join(t.TempDir(), ".ip-ranges.json")
+	config.IpRangesUrl = ipRangesServer.URL
+	config.Principals = []string{"arn:aws:iam::111111111111:role/deploy"}
+	awsConfig := aws.Config{
+		Region:      "eu-west-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		EndpointResolverWithOptions: aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: awsServer.URL, HostnameImmutable: true}, nil
+		}),
+		Retryer: func() aws.Retryer { return aws.NopRetryer{} },
+	}
+
+	var wg sync.WaitGroup
+	results := make([]Result, testSites+1)
+	errs := make([]error, testSites+1)
+	for n := 1; n <= testSites; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			e := New(context.Background(), config)
+			e.UseAwsConfig(awsConfig)
+			results[n], errs[n] = e.Explore(fmt.Sprintf("http://site%d.example.com/docs/%d/", n, n))
+			if _, cfg, ok := e.DistributionAccounts(); !ok || cfg.Region != awsConfig.Region {
+				t.Errorf("site%d: unexpected distribution account %v %s", n, ok, cfg.Region)
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	for n := 1; n <= testSites; n++ {
+		result, err := results[n], errs[n]
+		if err != nil {
+			t.Errorf("site%d: %v", n, err)
+			continue
+		}
+		target := result.TargetDomain
+		if target.DomainName != fmt.Sprintf("site%d.example.com", n) || target.RequestPath != fmt.Sprintf("/docs/%d/", n) {
+			t.Errorf("site%d: unexpected target %s %s", n, target.DomainName, target.RequestPath)
+		}
+		if target.TargetIpAddress != fmt.Sprintf("13.224.0.%d", n) || target.TargetService != "CLOUDFRONT" {
+			t.Errorf("site%d: unexpected target service %s %s", n, target.TargetIpAddress, target.TargetService)
+		}
+		if target.EtagResponse != fmt.Sprintf("etag-site%d", n) {
+			t.Errorf("site%d: unexpected served ETag %s", n, target.EtagResponse)
+		}
+		if result.Distribution.Id != fmt.Sprintf("E%d", n) || result.Distribution.DefaultRootObject != "index.html" {
+			t.Errorf("site%d: unexpected distribution %+v", n, result.Distribution)
+		}
+		if result.Route == nil || result.Route.OriginId != fmt.Sprintf("s3-site%d", n) {
+			t.Errorf("site%d: unexpected route %+v", n, result.Route)
+		}
+		if len(result.CloudFrontOrigins) != 1 {
+			t.Errorf("site%d: unexpected origins %+v", n, result.CloudFrontOrigins)
+			continue
+		}
+		o := result.CloudFrontOrigins[0]
+		if o.OriginName != fmt.Sprintf("site%d", n) || !o.OriginResourceExists || o.OriginIndexETag != fmt.Sprintf("etag-site%d", n) {
+			t.Errorf("site%d: unexpected origin %+v", n, o)
+		}
+		if o.OriginUrlResponse.StatusCode != http.StatusForbidden {
+			t.Errorf("site%d: unexpected origin response %d", n, o.OriginUrlResponse.StatusCode)
+		}
+		// Anonymous users, the OAI and the configured principal
+		if len(o.OriginObjectAccess) != 3 || !strings.HasSuffix(o.OriginObjectAccess[1].Principal, fmt.Sprintf("EOAI%d", n)) || o.OriginObjectAccess[1].Decision != "Allow" {
+			t.Errorf("site%d: unexpected object access %+v", n, o.OriginObjectAccess)
+		}
+	}
+}
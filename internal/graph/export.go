@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var dotShapes = map[NodeKind]string{
+	NodeUrl:           "oval",
+	NodeRoute53Record: "note",
+	NodeDistribution:  "box3d",
+	NodeWaf:           "octagon",
+	NodeOrigin:        "cylinder",
+	NodeBucketPolicy:  "component",
+	NodePublic:        "doublecircle",
+}
+
+// Mermaid node shapes, the label goes between the opening and closing marks
+var mermaidShapes = map[NodeKind][2]string{
+	NodeUrl:           {"([", "])"},
+	NodeRoute53Record: {"[/", "/]"},
+	NodeDistribution:  {"[[", "]]"},
+	NodeWaf:           {"{{", "}}"},
+	NodeOrigin:        {"[(", ")]"},
+	NodeBucketPolicy:  {"[\\", "\\]"},
+	NodePublic:        {"((", "))"},
+}
+
+func edgeLabel(e Edge) string {
+	if e.Label == "" {
+		return string(e.Kind)
+	}
+	return string(e.Kind) + " " + e.Label
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language
+func (g Graph) WriteDOT(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph columbus {")
+	fmt.Fprintln(b, "  rankdir=LR;")
+	fmt.Fprintln(b, "  node [shape=box, fontname=\"Helvetica\"];")
+	fmt.Fprintln(b, "  edge [fontname=\"Helvetica\", fontsize=10];")
+	for _, n := range g.Nodes {
+		shape := dotShapes[n.Kind]
+		if shape == "" {
+			shape = "box"
+		}
+		fmt.Fprintf(b, "  %s [label=%s, shape=%s];\n", strconv.Quote(n.Id), strconv.Quote(n.Label), shape)
+	}
+	for _, e := range g.Edges {
+		style := ""
+		if e.Kind == EdgeDenies {
+			style = ", style=dashed, color=red"
+		}
+		fmt.Fprintf(b, "  %s -> %s [label=%s%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(edgeLabel(e)), style)
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+// mermaidText escapes a label for a quoted Mermaid string
+func mermaidText(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	return strings.ReplaceAll(s, "\n", "<br/>")
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart, nodes are numbered
+// since Mermaid ids cannot contain the characters of ARNs and domains.
+func (g Graph) WriteMermaid(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	ids := make(map[string]string, len(g.Nodes))
+	fmt.Fprintln(b, "flowchart LR")
+	for i, n := range g.Nodes {
+		ids[n.Id] = "n" + strconv.Itoa(i)
+		shape, ok := mermaidShapes[n.Kind]
+		if !ok {
+			shape = [2]string{"[", "]"}
+		}
+		fmt.Fprintf(b, "  %s%s\"%s\"%s\n", ids[n.Id], shape[0], mermaidText(n.Label), shape[1])
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Kind == EdgeDenies {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(b, "  %s %s|\"%s\"| %s\n", ids[e.From], arrow, mermaidText(edgeLabel(e)), ids[e.To])
+	}
+	return b.Flush()
+}
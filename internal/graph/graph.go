@@ -0,0 +1,213 @@
+// Package graph models the topology behind a URL, from its DNS record to the
+// CloudFront distribution, its origins and who can read them, and exports it
+// as Graphviz DOT or Mermaid.
+package graph
+
+import (
+	"strings"
+
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+	"github.com/unfor19/columbus-app/internal/aws/service/iam"
+	croute53 "github.com/unfor19/columbus-app/internal/aws/service/route53"
+)
+
+type NodeKind string
+
+const (
+	NodeUrl           NodeKind = "url"
+	NodeRoute53Record NodeKind = "route53-record"
+	NodeIpAddress     NodeKind = "ip-address"
+	NodeService       NodeKind = "service"
+	NodeDistribution  NodeKind = "cloudfront-distribution"
+	NodeWaf           NodeKind = "waf"
+	NodeOrigin        NodeKind = "origin"
+	NodeOriginAccess  NodeKind = "origin-access"
+	NodeBucketPolicy  NodeKind = "bucket-policy"
+	NodePublic        NodeKind = "public"
+)
+
+type EdgeKind string
+
+const (
+	EdgeResolvesTo EdgeKind = "resolves-to"
+	EdgePointsTo   EdgeKind = "points-to"
+	EdgeServedBy   EdgeKind = "served-by"
+	EdgeProtects   EdgeKind = "protects"
+	EdgeRoutesTo   EdgeKind = "routes-to"
+	EdgeSignsWith  EdgeKind = "signs-with"
+	EdgeGovernedBy EdgeKind = "governed-by"
+	EdgeAllows     EdgeKind = "allows"
+	EdgeDenies     EdgeKind = "denies"
+)
+
+type Node struct {
+	Id    string
+	Kind  NodeKind
+	Label string
+}
+
+type Edge struct {
+	From  string
+	To    string
+	Kind  EdgeKind
+	Label string `json:",omitempty"`
+}
+
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+}
+
+// AddNode adds a node unless a node with the same id exists, and returns the
+// node's id.
+func (g *Graph) AddNode(kind NodeKind, key string, label string) string {
+	id := string(kind) + ":" + key
+	for _, n := range g.Nodes {
+		if n.Id == id {
+			return id
+		}
+	}
+	g.Nodes = append(g.Nodes, Node{Id: id, Kind: kind, Label: label})
+	return id
+}
+
+func (g *Graph) AddEdge(from string, to string, kind EdgeKind, label string) {
+	for _, e := range g.Edges {
+		if e.From == from && e.To == to && e.Kind == kind {
+			return
+		}
+	}
+	g.Edges = append(g.Edges, Edge{From: from, To: to, Kind: kind, Label: label})
+}
+
+func isNone(s string) bool {
+	return s == "" || s == "none"
+}
+
+// New builds the graph of a mapping, nodes are ordered from the URL towards
+// the origins.
+func New(mapping ccloudfront.AwsMapping) Graph {
+	var g Graph
+	target := mapping.TargetDomain
+	entry := g.AddNode(NodeUrl, target.DomainName, target.DomainName)
+
+	addRoute53Records(&g, entry, mapping)
+	if len(target.Route53Records) == 0 && !isNone(target.Route53Record) {
+		record := g.AddNode(NodeRoute53Record, target.Route53Record, "Route53 "+strings.TrimSuffix(target.Route53Record, "."))
+		g.AddEdge(entry, record, EdgeResolvesTo, "")
+	}
+	if target.TargetIpAddress != "" {
+		ip := g.AddNode(NodeIpAddress, target.TargetIpAddress, target.TargetIpAddress)
+		g.AddEdge(entry, ip, EdgeResolvesTo, "")
+		if target.TargetService != "" {
+			service := g.AddNode(NodeService, target.TargetService, target.TargetService)
+			g.AddEdge(ip, service, EdgeServedBy, "")
+			entry = service
+		} else {
+			entry = ip
+		}
+	}
+
+	distribution := mapping.Distribution
+	if distribution.Id == "" {
+		return g
+	}
+	d := g.AddNode(NodeDistribution, distribution.Id, distributionLabel(distribution.Id, distribution.DomainName))
+	g.AddEdge(entry, d, EdgeServedBy, "")
+	if !isNone(target.WafId) {
+		waf := g.AddNode(NodeWaf, target.WafId, "WAF "+target.WafId)
+		g.AddEdge(waf, d, EdgeProtects, "")
+	}
+
+	for _, o := range mapping.CloudFrontOrigins {
+		label := o.OriginType + " " + o.OriginName + o.OriginPath
+		origin := g.AddNode(NodeOrigin, o.OriginType+":"+o.OriginName+o.OriginPath, label)
+		g.AddEdge(d, origin, EdgeRoutesTo, o.OriginPath)
+		if !strings.HasPrefix(o.OriginType, "s3") || !o.OriginResourceExists {
+			continue
+		}
+		policy := g.AddNode(NodeBucketPolicy, o.OriginName, "Bucket policy "+o.OriginName)
+		g.AddEdge(origin, policy, EdgeGovernedBy, "")
+		addOriginAccess(&g, d, policy, o)
+	}
+	return g
+}
+
+func distributionLabel(id string, domainName string) string {
+	return "CloudFront " + id + "\n" + domainName
+}
+
+// recordTargets are the lower case names that the alias target or the CNAME
+// values of the record point to
+func recordTargets(r croute53.Route53Record) map[string]bool {
+	targets := map[string]bool{}
+	if r.AliasTarget != nil {
+		targets[strings.ToLower(strings.TrimSuffix(r.AliasTarget.DNSName, "."))] = true
+	}
+	if r.Type == "CNAME" {
+		for _, v := range r.Values {
+			targets[strings.ToLower(strings.TrimSuffix(v, "."))] = true
+		}
+	}
+	return targets
+}
+
+// addRoute53Records links the URL to each of its Route53 record sets, and
+// each record set to the distribution its DNS target was matched to.
+func addRoute53Records(g *Graph, url string, mapping ccloudfront.AwsMapping) {
+	for _, r := range mapping.TargetDomain.Route53Records {
+		name := r.Name
+		if r.SetIdentifier != "" {
+			name += " (" + r.SetIdentifier + ")"
+		}
+		label := "Route53 " + strings.TrimSuffix(r.Name, ".") + " " + r.Type
+		if r.SetIdentifier != "" {
+			label += " " + r.SetIdentifier
+		}
+		record := g.AddNode(NodeRoute53Record, r.ZoneId+":"+name+":"+r.Type, label)
+		g.AddEdge(url, record, EdgeResolvesTo, "")
+
+		targets := recordTargets(r)
+		for _, t := range mapping.TargetDomain.DnsTargets {
+			if t.Source == ccloudfront.DnsTargetResolution || t.Record != name || !targets[t.DomainName] {
+				continue
+			}
+			d := g.AddNode(NodeDistribution, t.DomainName, "CloudFront "+t.DomainName+"\nnot in the explored accounts")
+			if t.DistributionId != "" {
+				d = g.AddNode(NodeDistribution, t.DistributionId, distributionLabel(t.DistributionId, t.DomainName))
+			}
+			g.AddEdge(record, d, EdgePointsTo, "")
+		}
+	}
+}
+
+// addOriginAccess links the distribution to its OAI or OAC, and the bucket
+// policy to the OAI or OAC and anonymous users with the policy's decision.
+func addOriginAccess(g *Graph, distribution string, policy string, o ccloudfront.CloudFrontOrigin) {
+	var access string
+	if o.OriginAccessIdentity != "" {
+		parts := strings.Split(o.OriginAccessIdentity, "/")
+		access = g.AddNode(NodeOriginAccess, "oai:"+parts[len(parts)-1], "OAI "+parts[len(parts)-1])
+	} else if o.OriginAccessControlId != "" {
+		access = g.AddNode(NodeOriginAccess, "oac:"+o.OriginAccessControlId, "OAC "+o.OriginAccessControlId)
+	}
+	if access != "" {
+		g.AddEdge(distribution, access, EdgeSignsWith, "")
+	}
+	for _, e := range o.OriginObjectAccess {
+		principal := ""
+		switch {
+		case e.Principal == iam.AnonymousPrincipal.String():
+			principal = g.AddNode(NodePublic, "anonymous", "Anonymous users")
+		case access != "" && (e.Principal == iam.CloudFrontServicePrincipal || iam.IsOriginAccessIdentityArn(e.Principal)):
+			principal = access
+		default:
+			continue
+		}
+		kind := EdgeAllows
+		if e.Decision != iam.DecisionAllow {
+			kind = EdgeDenies
+		}
+		g.AddEdge(policy, principal, kind, e.Action+" "+string(e.Decision))
+	}
+}
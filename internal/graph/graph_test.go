@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+	"github.com/unfor19/columbus-app/internal/aws/service/iam"
+	croute53 "github.com/unfor19/columbus-app/internal/aws/service/route53"
+)
+
+func testMapping() ccloudfront.AwsMapping {
+	return ccloudfront.AwsMapping{
+		Distribution: ccloudfront.CloudFrontDistribution{Id: "E1A2B3C4D5E6F7", DomainName: "d111111abcdef8.cloudfront.net"},
+		CloudFrontOrigins: []ccloudfront.CloudFrontOrigin{
+			{
+				OriginType:           "s3",
+				OriginName:           "dev.sokker.info",
+				OriginResourceExists: true,
+				OriginAccessIdentity: "origin-access-identity/cloudfront/E2QWRUHAPOMQZL",
+				OriginObjectAccess: []iam.Evaluation{
+					{Principal: "anonymous", Action: "s3:GetObject", Decision: iam.DecisionImplicitDeny},
+					{Principal: "arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity E2QWRUHAPOMQZL", Action: "s3:GetObject", Decision: iam.DecisionAllow},
+				},
+			},
+			{OriginType: "apigw", OriginName: "abc.execute-api.eu-west-1.amazonaws.com", OriginPath: "/dev"},
+		},
+		TargetDomain: ccloudfront.TargetAttributes{
+			DomainName:      "dev.sokker.info",
+			TargetIpAddress: "13.225.250.115",
+			TargetService:   "CLOUDFRONT",
+			Route53Record:   "dev.sokker.info.",
+			WafId:           "none",
+		},
+	}
+}
+
+func hasEdge(g Graph, from string, to string, kind EdgeKind) bool {
+	for _, e := range g.Edges {
+		if e.From == from && e.To == to && e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUrlIsSource fails when an edge points to the URL, the URL resolves to
+// its records whichever way they were found
+func checkUrlIsSource(t *testing.T, g Graph) {
+	t.Helper()
+	for _, e := range g.Edges {
+		if e.To == "url:dev.sokker.info" {
+			t.Errorf("unexpected edge %s -%s-> %s", e.From, e.Kind, e.To)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	g := New(testMapping())
+
+	expected := []struct {
+		from string
+		to   string
+		kind EdgeKind
+	}{
+		{"url:dev.sokker.info", "route53-record:dev.sokker.info.", EdgeResolvesTo},
+		{"url:dev.sokker.info", "ip-address:13.225.250.115", EdgeResolvesTo},
+		{"service:CLOUDFRONT", "cloudfront-distribution:E1A2B3C4D5E6F7", EdgeServedBy},
+		{"cloudfront-distribution:E1A2B3C4D5E6F7", "origin:s3:dev.sokker.info", EdgeRoutesTo},
+		{"cloudfront-distribution:E1A2B3C4D5E6F7", "origin:apigw:abc.execute-api.eu-west-1.amazonaws.com/dev", EdgeRoutesTo},
+		{"origin:s3:dev.sokker.info", "bucket-policy:dev.sokker.info", EdgeGovernedBy},
+		{"cloudfront-distribution:E1A2B3C4D5E6F7", "origin-access:oai:E2QWRUHAPOMQZL", EdgeSignsWith},
+		{"bucket-policy:dev.sokker.info", "origin-access:oai:E2QWRUHAPOMQZL", EdgeAllows},
+		{"bucket-policy:dev.sokker.info", "public:anonymous", EdgeDenies},
+	}
+	for _, e := range expected {
+		if !hasEdge(g, e.from, e.to, e.kind) {
+			t.Errorf("missing edge %s -%s-> %s", e.from, e.kind, e.to)
+		}
+	}
+	checkUrlIsSource(t, g)
+	for _, n := range g.Nodes {
+		if n.Kind == NodeWaf {
+			t.Errorf("unexpected WAF node %s", n.Id)
+		}
+	}
+	if len(g.Edges) != len(expected)+1 {
+		t.Errorf("expected %d edges, got %d: %v", len(expected)+1, len(g.Edges), g.Edges)
+	}
+}
+
+func TestNewRoute53Records(t *testing.T) {
+	mapping := testMapping()
+	mapping.TargetDomain.Route53Records = []croute53.Route53Record{
+		{ZoneId: "Z1", Name: "dev.sokker.info.", Type: "A", SetIdentifier: "blue", AliasTarget: &croute53.AliasTarget{DNSName: "d111111abcdef8.cloudfront.net."}},
+		{ZoneId: "Z1", Name: "dev.sokker.info.", Type: "A", SetIdentifier: "green", AliasTarget: &croute53.AliasTarget{DNSName: "d222222abcdef8.cloudfront.net."}},
+	}
+	mapping.TargetDomain.DnsTargets = []ccloudfront.DnsTarget{
+		{Source: ccloudfront.DnsTargetRoute53Alias, Record: "dev.sokker.info. (blue)", DomainName: "d111111abcdef8.cloudfront.net", DistributionId: "E1A2B3C4D5E6F7"},
+		{Source: ccloudfront.DnsTargetRoute53Alias, Record: "dev.sokker.info. (green)", DomainName: "d222222abcdef8.cloudfront.net"},
+	}
+	g := New(mapping)
+
+	// URL -> Route53 record -> CloudFront distribution -> origin
+	blue := "route53-record:Z1:dev.sokker.info. (blue):A"
+	green := "route53-record:Z1:dev.sokker.info. (green):A"
+	expected := []struct {
+		from string
+		to   string
+		kind EdgeKind
+	}{
+		{"url:dev.sokker.info", blue, EdgeResolvesTo},
+		{blue, "cloudfront-distribution:E1A2B3C4D5E6F7", EdgePointsTo},
+		{"cloudfront-distribution:E1A2B3C4D5E6F7", "origin:s3:dev.sokker.info", EdgeRoutesTo},
+		{"url:dev.sokker.info", green, EdgeResolvesTo},
+		{green, "cloudfront-distribution:d222222abcdef8.cloudfront.net", EdgePointsTo},
+	}
+	for _, e := range expected {
+		if !hasEdge(g, e.from, e.to, e.kind) {
+			t.Errorf("missing edge %s -%s-> %s", e.from, e.kind, e.to)
+		}
+	}
+	checkUrlIsSource(t, g)
+	// The legacy record name is replaced by the record sets
+	for _, n := range g.Nodes {
+		if n.Id == "route53-record:dev.sokker.info." {
+			t.Errorf("unexpected legacy record node %s", n.Id)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	g := New(testMapping())
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"digraph columbus {",
+		`"cloudfront-distribution:E1A2B3C4D5E6F7" [label="CloudFront E1A2B3C4D5E6F7\nd111111abcdef8.cloudfront.net", shape=box3d];`,
+		`"bucket-policy:dev.sokker.info" -> "public:anonymous" [label="denies s3:GetObject ImplicitDeny", style=dashed, color=red];`,
+	} {
+		if !strings.Contains(dot.String(), s) {
+			t.Errorf("DOT does not contain %q:\n%s", s, dot.String())
+		}
+	}
+
+	var mermaid bytes.Buffer
+	if err := g.WriteMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"flowchart LR",
+		`n0(["dev.sokker.info"])`,
+		`[["CloudFront E1A2B3C4D5E6F7<br/>d111111abcdef8.cloudfront.net"]]`,
+		`-.->|"denies s3:GetObject ImplicitDeny"|`,
+	} {
+		if !strings.Contains(mermaid.String(), s) {
+			t.Errorf("Mermaid does not contain %q:\n%s", s, mermaid.String())
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package insights
+
+import (
+	"fmt"
+	"strings"
+
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+)
+
+// rule adapts a plain function to the Insight interface.
+type rule struct {
+	id          string
+	description string
+	evaluate    func(mapping ccloudfront.AwsMapping) []Finding
+}
+
+func (r rule) Id() string {
+	return r.id
+}
+
+func (r rule) Description() string {
+	return r.description
+}
+
+func (r rule) Evaluate(mapping ccloudfront.AwsMapping) []Finding {
+	return r.evaluate(mapping)
+}
+
+func init() {
+	Default.MustRegister(rule{
+		id:          "cloudfront-waf",
+		description: "The CloudFront distribution should be protected by a WAF web ACL",
+		evaluate:    evaluateCloudFrontWaf,
+	})
+	Default.MustRegister(rule{
+		id:          "s3-origin-exists",
+		description: "Every S3 origin of the CloudFront distribution should point to an existing bucket",
+		evaluate:    evaluateS3OriginExists,
+	})
+	Default.MustRegister(rule{
+		id:          "cloudfront-stale-index",
+		description: "The index object served by CloudFront should match the one stored in the S3 origin",
+		evaluate:    evaluateCloudFrontStaleIndex,
+	})
+	Default.MustRegister(rule{
+		id:          "cloudfront-dns-target",
+		description: "The DNS records of the domain should point to the CloudFront distribution that serves it",
+		evaluate:    evaluateCloudFrontDnsTarget,
+	})
+	Default.MustRegister(rule{
+		id:          "cloudfront-ambiguous-distribution",
+		description: "A single CloudFront distribution should match the domain by its aliases",
+		evaluate:    evaluateCloudFrontAmbiguousDistribution,
+	})
+}
+
+func evaluateCloudFrontWaf(mapping ccloudfront.AwsMapping) []Finding {
+	if mapping.TargetDomain.WafId != "none" {
+		return nil
+	}
+	return []Finding{{
+		Title:    "CloudFront distribution has no WAF",
+		Severity: SeverityLow,
+		Resource: mapping.TargetDomain.DomainName,
+		Message:  "The distribution serving " + mapping.TargetDomain.DomainName + " is not associated with a WAF web ACL",
+		Evidence: []Evidence{
+			{Name: "WafId", Value: mapping.TargetDomain.WafId},
+		},
+		Remediation: "Associate a WAF web ACL with the CloudFront distribution",
+	}}
+}
+
+func evaluateS3OriginExists(mapping ccloudfront.AwsMapping) []Finding {
+	var findings []Finding
+	for _, o := range mapping.CloudFrontOrigins {
+		if !strings.HasPrefix(o.OriginType, "s3") || o.OriginResourceExists {
+			continue
+		}
+		findings = append(findings, Finding{
+			Title:    "S3 origin bucket not found",
+			Severity: SeverityHigh,
+			Resource: o.OriginName,
+			Message:  "The origin " + o.OriginUrl + " points to the bucket " + o.OriginName + " which does not exist or is not accessible",
+			Evidence: []Evidence{
+				{Name: "OriginType", Value: o.OriginType},
+				{Name: "OriginUrl", Value: o.OriginUrl},
+			},
+			Remediation: "Create the bucket or remove the origin from the CloudFront distribution, a missing bucket can be claimed by anyone",
+		})
+	}
+	return findings
+}
+
+// requestsIndex reports whether the request URL is served by the index
+// object of the origin, the other paths serve objects whose ETags differ
+// from the index's
+func requestsIndex(mapping ccloudfront.AwsMapping, o ccloudfront.CloudFrontOrigin) bool {
+	target := mapping.TargetDomain
+	return o.ObjectKey(target.RequestPath, mapping.Distribution.DefaultRootObject, target.IndexFilePath) == o.OriginIndexKey
+}
+
+func evaluateCloudFrontStaleIndex(mapping ccloudfront.AwsMapping) []Finding {
+	servedETag := mapping.TargetDomain.EtagResponse
+	if servedETag == "" {
+		return nil
+	}
+	var stale []ccloudfront.CloudFrontOrigin
+	for _, o := range mapping.CloudFrontOrigins {
+		if !strings.HasPrefix(o.OriginType, "s3") || o.OriginIndexETag == "" || !requestsIndex(mapping, o) {
+			continue
+		}
+		if o.OriginIndexETag == servedETag {
+			// One of the origins serves the current object
+			return nil
+		}
+		stale = append(stale, o)
+	}
+
+	var findings []Finding
+	path := mapping.TargetDomain.RequestPath
+	if path == "" {
+		path = "/"
+	}
+	for _, o := range stale {
+		findings = append(findings, Finding{
+			Title:    "CloudFront serves a stale index object",
+			Severity: SeverityMedium,
+			Resource: mapping.Distribution.Id,
+			Message:  "The ETag served by CloudFront for " + mapping.TargetDomain.DomainName + path + " differs from the ETag of " + o.OriginIndexKey + " in the bucket " + o.OriginName,
+			Evidence: append(originEvidence(o),
+				Evidence{Name: "ServedETag", Value: servedETag},
+				Evidence{Name: "OriginIndexETag", Value: o.OriginIndexETag},
+				Evidence{Name: "OriginIndexKey", Value: o.OriginIndexKey},
+				Evidence{Name: "RequestPath", Value: path},
+			),
+			Remediation: fmt.Sprintf(
+				`Invalidate the index object, POST /remediate/invalidate {"DistributionId": %q, "Paths": [%q], "DryRun": false}`,
+				mapping.Distribution.Id, path,
+			),
+		})
+	}
+	return findings
+}
+
+func evaluateCloudFrontDnsTarget(mapping ccloudfront.AwsMapping) []Finding {
+	var findings []Finding
+	for _, t := range mapping.TargetDomain.DnsTargets {
+		evidence := []Evidence{
+			{Name: "Source", Value: t.Source},
+			{Name: "Record", Value: t.Record},
+			{Name: "DnsTarget", Value: t.DomainName},
+		}
+		switch {
+		case t.DistributionId == "":
+			findings = append(findings, Finding{
+				Title:       "DNS points to an unknown CloudFront distribution",
+				Severity:    SeverityHigh,
+				Resource:    t.Record,
+				Message:     "The record " + t.Record + " points to " + t.DomainName + " which is not a distribution of the explored accounts, it may have been deleted",
+				Evidence:    evidence,
+				Remediation: "Point the record to the distribution that serves " + mapping.TargetDomain.DomainName + ", or remove it if the distribution was deleted",
+			})
+		case mapping.Distribution.Id == "":
+			findings = append(findings, Finding{
+				Title:    "DNS points to a CloudFront distribution without an alias for the domain",
+				Severity: SeverityHigh,
+				Resource: t.Record,
+				Message:  "The record " + t.Record + " points to the distribution " + t.DistributionId + ", but no distribution of the explored accounts has " + mapping.TargetDomain.DomainName + " as an alias, CloudFront rejects the requests and the alias can be added to a distribution of another account",
+				Evidence: append(evidence,
+					Evidence{Name: "DnsDistributionId", Value: t.DistributionId},
+				),
+				Remediation: "Add " + mapping.TargetDomain.DomainName + " as an alias of the distribution " + t.DistributionId + ", or remove the record",
+			})
+		case t.DistributionId != mapping.Distribution.Id:
+			findings = append(findings, Finding{
+				Title:    "DNS points to another CloudFront distribution",
+				Severity: SeverityMedium,
+				Resource: t.Record,
+				Message:  "The record " + t.Record + " points to the distribution " + t.DistributionId + ", not to " + mapping.Distribution.Id + " which has " + mapping.TargetDomain.DomainName + " as an alias",
+				Evidence: append(evidence,
+					Evidence{Name: "DnsDistributionId", Value: t.DistributionId},
+					Evidence{Name: "DistributionId", Value: mapping.Distribution.Id},
+				),
+				Remediation: "Point the record to " + mapping.Distribution.DomainName + ", or move the alias to the distribution the record points to",
+			})
+		}
+	}
+	return findings
+}
+
+func evaluateCloudFrontAmbiguousDistribution(mapping ccloudfront.AwsMapping) []Finding {
+	matches := mapping.TargetDomain.DistributionMatches
+	if !ccloudfront.Ambiguous(matches) {
+		return nil
+	}
+	var evidence []Evidence
+	for _, m := range matches {
+		if m.Wildcard != matches[0].Wildcard {
+			break
+		}
+		evidence = append(evidence, Evidence{Name: m.Id, Value: m.Alias})
+	}
+	return []Finding{{
+		Title:       "Several CloudFront distributions match the domain",
+		Severity:    SeverityMedium,
+		Resource:    mapping.TargetDomain.DomainName,
+		Message:     fmt.Sprintf("%d distributions have an alias that matches %s, Columbus explored %s", len(evidence), mapping.TargetDomain.DomainName, mapping.Distribution.Id),
+		Evidence:    evidence,
+		Remediation: "Keep the alias on the distribution that serves " + mapping.TargetDomain.DomainName + " and remove it from the others",
+	}}
+}
@@ -0,0 +1,155 @@
+package insights
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+)
+
+type Severity string
+
+const (
+	SeverityInfo   Severity = "info"
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+var severityRanks = map[Severity]int{
+	SeverityInfo:   0,
+	SeverityLow:    1,
+	SeverityMedium: 2,
+	SeverityHigh:   3,
+}
+
+func (s Severity) Rank() int {
+	return severityRanks[s]
+}
+
+func ParseSeverity(s string) (Severity, error) {
+	if _, ok := severityRanks[Severity(s)]; !ok {
+		return "", fmt.Errorf("unknown severity %q", s)
+	}
+	return Severity(s), nil
+}
+
+type Evidence struct {
+	Name  string
+	Value string
+}
+
+type Finding struct {
+	Insight     string
+	Title       string
+	Severity    Severity
+	Resource    string
+	Message     string
+	Verdict     string `json:",omitempty"`
+	Evidence    []Evidence
+	Remediation string `json:",omitempty"`
+}
+
+// Insight is a rule that inspects an AwsMapping and reports findings about it.
+type Insight interface {
+	Id() string
+	Description() string
+	Evaluate(mapping ccloudfront.AwsMapping) []Finding
+}
+
+type Registry struct {
+	mu       sync.RWMutex
+	insights []Insight
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default holds the insights that are shipped with Columbus, they register
+// themselves on init.
+var Default = NewRegistry()
+
+func (r *Registry) Register(insight Insight) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, i := range r.insights {
+		if i.Id() == insight.Id() {
+			return fmt.Errorf("insight %q is already registered", insight.Id())
+		}
+	}
+	r.insights = append(r.insights, insight)
+	return nil
+}
+
+func (r *Registry) MustRegister(insight Insight) {
+	if err := r.Register(insight); err != nil {
+		panic(err)
+	}
+}
+
+func (r *Registry) Insights() []Insight {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Insight(nil), r.insights...)
+}
+
+// Select returns a registry with the enabled insights, or all of them when
+// enabled is empty, without the disabled ones. Unknown ids are an error.
+func (r *Registry) Select(enabled []string, disabled []string) (*Registry, error) {
+	registered := map[string]bool{}
+	for _, insight := range r.Insights() {
+		registered[insight.Id()] = true
+	}
+	for _, id := range append(append([]string(nil), enabled...), disabled...) {
+		if !registered[id] {
+			return nil, fmt.Errorf("unknown insight %q", id)
+		}
+	}
+	contains := func(ids []string, id string) bool {
+		for _, i := range ids {
+			if i == id {
+				return true
+			}
+		}
+		return false
+	}
+	selected := NewRegistry()
+	for _, insight := range r.Insights() {
+		if (len(enabled) == 0 || contains(enabled, insight.Id())) && !contains(disabled, insight.Id()) {
+			selected.MustRegister(insight)
+		}
+	}
+	return selected, nil
+}
+
+// Evaluate runs all registered insights, the findings are sorted by severity,
+// highest first, and keep the registration order within the same severity.
+func (r *Registry) Evaluate(mapping ccloudfront.AwsMapping) []Finding {
+	findings := []Finding{}
+	for _, insight := range r.Insights() {
+		for _, f := range insight.Evaluate(mapping) {
+			if f.Insight == "" {
+				f.Insight = insight.Id()
+			}
+			findings = append(findings, f)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity.Rank() > findings[j].Severity.Rank()
+	})
+	return findings
+}
+
+// MaxSeverity returns the highest severity of findings, or an empty Severity
+// when there are none.
+func MaxSeverity(findings []Finding) Severity {
+	var max Severity
+	for _, f := range findings {
+		if max == "" || f.Severity.Rank() > max.Rank() {
+			max = f.Severity
+		}
+	}
+	return max
+}
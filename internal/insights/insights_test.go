@@ -0,0 +1,333 @@
+package insights
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+	"github.com/unfor19/columbus-app/internal/aws/service/iam"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
+	"github.com/unfor19/columbus-app/pkg/traffic"
+)
+
+func findingsOf(findings []Finding, insight string) []Finding {
+	var matched []Finding
+	for _, f := range findings {
+		if f.Insight == insight {
+			matched = append(matched, f)
+		}
+	}
+	return matched
+}
+
+func TestRegistryRejectsDuplicates(t *testing.T) {
+	r := NewRegistry()
+	i := rule{id: "dup", evaluate: func(ccloudfront.AwsMapping) []Finding { return nil }}
+	if err := r.Register(i); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(i); err == nil {
+		t.Fatal("Expected duplicate insight to be rejected")
+	}
+}
+
+func TestRegistrySortsBySeverity(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(rule{id: "low", evaluate: func(ccloudfront.AwsMapping) []Finding {
+		return []Finding{{Severity: SeverityLow}}
+	}})
+	r.MustRegister(rule{id: "high", evaluate: func(ccloudfront.AwsMapping) []Finding {
+		return []Finding{{Severity: SeverityHigh}}
+	}})
+	findings := r.Evaluate(ccloudfront.AwsMapping{})
+	if len(findings) != 2 || findings[0].Insight != "high" || findings[1].Insight != "low" {
+		t.Fatal("Unexpected findings order", findings)
+	}
+	if MaxSeverity(findings) != SeverityHigh {
+		t.Fatal("Unexpected max severity", MaxSeverity(findings))
+	}
+}
+
+func TestRegistrySelect(t *testing.T) {
+	r := NewRegistry()
+	for _, id := range []string{"a", "b", "c"} {
+		r.MustRegister(rule{id: id, evaluate: func(ccloudfront.AwsMapping) []Finding { return nil }})
+	}
+	ids := func(r *Registry) (ids []string) {
+		for _, i := range r.Insights() {
+			ids = append(ids, i.Id())
+		}
+		return ids
+	}
+	if selected, err := r.Select([]string{"a", "c"}, nil); err != nil || !reflect.DeepEqual(ids(selected), []string{"a", "c"}) {
+		t.Fatal("Unexpected enabled insights", ids(selected), err)
+	}
+	if selected, err := r.Select(nil, []string{"b"}); err != nil || !reflect.DeepEqual(ids(selected), []string{"a", "c"}) {
+		t.Fatal("Unexpected insights without the disabled ones", ids(selected), err)
+	}
+	if _, err := r.Select([]string{"d"}, nil); err == nil {
+		t.Fatal("Expected an error for an unknown insight")
+	}
+}
+
+func TestDefaultInsights(t *testing.T) {
+	mapping := ccloudfront.AwsMapping{
+		CloudFrontOrigins: []ccloudfront.CloudFrontOrigin{
+			{OriginType: "s3-bucket", OriginName: "missing-bucket", OriginResourceExists: false},
+			{OriginType: "s3-bucket", OriginName: "existing-bucket", OriginResourceExists: true},
+			{OriginType: "apigw", OriginName: "abcdef"},
+		},
+		TargetDomain: ccloudfront.TargetAttributes{DomainName: "dev.example.com", WafId: "none"},
+	}
+	findings := Default.Evaluate(mapping)
+	if f := findingsOf(findings, "cloudfront-waf"); len(f) != 1 {
+		t.Fatal("Expected a cloudfront-waf finding", findings)
+	}
+	if f := findingsOf(findings, "s3-origin-exists"); len(f) != 1 || f[0].Resource != "missing-bucket" {
+		t.Fatal("Expected a single s3-origin-exists finding", findings)
+	}
+}
+
+func TestS3OriginWebsiteHosting(t *testing.T) {
+	mapping := ccloudfront.AwsMapping{
+		CloudFrontOrigins: []ccloudfront.CloudFrontOrigin{
+			{OriginType: "s3-bucket", OriginName: "bucket-website", OriginResourceExists: true, OriginIsWebsite: true},
+			{OriginType: "s3-bucket", OriginName: "bucket-rest", OriginResourceExists: true, OriginIsWebsite: false},
+			{OriginType: "s3-website", OriginName: "website-disabled", OriginResourceExists: true, OriginIsWebsite: false},
+			{OriginType: "s3-website", OriginName: "website-enabled", OriginResourceExists: true, OriginIsWebsite: true},
+			{OriginType: "s3-website", OriginName: "website-missing", OriginResourceExists: false},
+		},
+	}
+	findings := evaluateS3OriginWebsiteHosting(mapping)
+	if len(findings) != 2 {
+		t.Fatal("Expected 2 findings, got", findings)
+	}
+	if findings[0].Resource != "bucket-website" || findings[0].Severity != SeverityMedium {
+		t.Fatal("Unexpected finding", findings[0])
+	}
+	if findings[1].Resource != "website-disabled" || findings[1].Severity != SeverityHigh {
+		t.Fatal("Unexpected finding", findings[1])
+	}
+}
+
+func TestS3OriginProtection(t *testing.T) {
+	oaiPolicy := iam.PolicyDocument{Statement: iam.Statements{{
+		Effect:    "Allow",
+		Action:    iam.Value{"s3:GetObject"},
+		Resource:  iam.Value{"arn:aws:s3:::bucket/*"},
+		Principal: &iam.Principal{AWS: iam.Value{"arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity EABC0KIJFBSUUS"}},
+	}}}
+	// The identity of another distribution
+	foreignOaiPolicy := iam.PolicyDocument{Statement: iam.Statements{{
+		Effect:    "Allow",
+		Action:    iam.Value{"s3:GetObject"},
+		Resource:  iam.Value{"arn:aws:s3:::bucket/*"},
+		Principal: &iam.Principal{AWS: iam.Value{"arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity EFOREIGN0OAI00"}},
+	}}}
+	tests := []struct {
+		name             string
+		cloudFrontStatus int
+		origin           ccloudfront.CloudFrontOrigin
+		verdict          string
+	}{
+		{"protected", 200, ccloudfront.CloudFrontOrigin{OriginBucketPolicy: oaiPolicy, OriginUrlResponse: traffic.UrlResponse{StatusCode: 403}}, VerdictProtected},
+		{"public policy", 200, ccloudfront.CloudFrontOrigin{OriginBucketPolicy: oaiPolicy, OriginBucketPolicyIsPublic: true, OriginUrlResponse: traffic.UrlResponse{StatusCode: 403}}, VerdictPubliclyBypassable},
+		{"direct access", 200, ccloudfront.CloudFrontOrigin{OriginUrlResponse: traffic.UrlResponse{StatusCode: 200}}, VerdictPubliclyBypassable},
+		{"no oai", 200, ccloudfront.CloudFrontOrigin{OriginUrlResponse: traffic.UrlResponse{StatusCode: 403}}, VerdictBroken},
+		{"foreign oai", 200, ccloudfront.CloudFrontOrigin{OriginBucketPolicy: foreignOaiPolicy, OriginUrlResponse: traffic.UrlResponse{StatusCode: 403}}, VerdictBroken},
+		{"cloudfront fails", 403, ccloudfront.CloudFrontOrigin{OriginBucketPolicy: oaiPolicy, OriginUrlResponse: traffic.UrlResponse{StatusCode: 403}}, VerdictBroken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.origin.OriginType = "s3-bucket"
+			tt.origin.OriginName = "bucket"
+			tt.origin.OriginResourceExists = true
+			tt.origin.OriginAccessIdentity = "origin-access-identity/cloudfront/EABC0KIJFBSUUS"
+			mapping := ccloudfront.AwsMapping{
+				CloudFrontOrigins: []ccloudfront.CloudFrontOrigin{tt.origin},
+				TargetDomain:      ccloudfront.TargetAttributes{UrlResponse: traffic.UrlResponse{StatusCode: tt.cloudFrontStatus}},
+			}
+			findings := evaluateS3OriginProtection(mapping)
+			if len(findings) != 1 || findings[0].Verdict != tt.verdict {
+				t.Fatal("Expected verdict", tt.verdict, "got", findings)
+			}
+		})
+	}
+}
+
+func TestCloudFrontStaleIndex(t *testing.T) {
+	mapping := ccloudfront.AwsMapping{
+		Distribution: ccloudfront.CloudFrontDistribution{Id: "E123", DefaultRootObject: "index.html"},
+		CloudFrontOrigins: []ccloudfront.CloudFrontOrigin{
+			{OriginType: "s3-bucket", OriginName: "bucket", OriginPath: "/production", OriginIndexKey: "production/index.html", OriginIndexETag: "new"},
+		},
+		TargetDomain: ccloudfront.TargetAttributes{DomainName: "dev.example.com", EtagResponse: "old", IndexFilePath: "index.html", RequestPath: "/"},
+	}
+	findings := evaluateCloudFrontStaleIndex(mapping)
+	if len(findings) != 1 || findings[0].Resource != "E123" {
+		t.Fatal("Expected a stale index finding", findings)
+	}
+	// The object under the origin path is compared
+	if !strings.Contains(findings[0].Message, "production/index.html") {
+		t.Fatal("Unexpected message", findings[0].Message)
+	}
+	if remediation := findings[0].Remediation; !strings.Contains(remediation, `{"DistributionId": "E123", "Paths": ["/"], "DryRun": false}`) {
+		t.Fatal("Unexpected remediation", remediation)
+	}
+	mapping.TargetDomain.RequestPath = "/index.html"
+	if findings := evaluateCloudFrontStaleIndex(mapping); len(findings) != 1 {
+		t.Fatal("Expected a stale index finding for the index path", findings)
+	}
+	// A deep link serves another object than the index
+	mapping.TargetDomain.RequestPath = "/docs/app.js"
+	if findings := evaluateCloudFrontStaleIndex(mapping); len(findings) != 0 {
+		t.Fatal("Expected no findings for a deep link", findings)
+	}
+	// The root serves another default root object than the index
+	mapping.TargetDomain.RequestPath = "/"
+	mapping.Distribution.DefaultRootObject = "home.html"
+	if findings := evaluateCloudFrontStaleIndex(mapping); len(findings) != 0 {
+		t.Fatal("Expected no findings for another default root object", findings)
+	}
+	mapping.Distribution.DefaultRootObject = "index.html"
+	mapping.TargetDomain.EtagResponse = "new"
+	if findings := evaluateCloudFrontStaleIndex(mapping); len(findings) != 0 {
+		t.Fatal("Expected no findings", findings)
+	}
+}
+
+func TestCloudFrontDnsTarget(t *testing.T) {
+	mapping := ccloudfront.AwsMapping{
+		Distribution: ccloudfront.CloudFrontDistribution{Id: "E123", DomainName: "d123.cloudfront.net"},
+		TargetDomain: ccloudfront.TargetAttributes{
+			DomainName: "dev.example.com",
+			DnsTargets: []ccloudfront.DnsTarget{
+				{Source: ccloudfront.DnsTargetRoute53Alias, Record: "dev.example.com.", DomainName: "d123.cloudfront.net", DistributionId: "E123"},
+			},
+		},
+	}
+	if findings := evaluateCloudFrontDnsTarget(mapping); len(findings) != 0 {
+		t.Fatal("Expected no findings", findings)
+	}
+	mapping.TargetDomain.DnsTargets[0].DistributionId = "E456"
+	if findings := evaluateCloudFrontDnsTarget(mapping); len(findings) != 1 || findings[0].Severity != SeverityMedium {
+		t.Fatal("Expected a medium finding", findings)
+	}
+	mapping.TargetDomain.DnsTargets[0].DistributionId = ""
+	if findings := evaluateCloudFrontDnsTarget(mapping); len(findings) != 1 || findings[0].Severity != SeverityHigh {
+		t.Fatal("Expected a high finding", findings)
+	}
+	// No distribution has the domain as an alias
+	mapping.Distribution = ccloudfront.CloudFrontDistribution{}
+	mapping.TargetDomain.DnsTargets[0].DistributionId = "E456"
+	if findings := evaluateCloudFrontDnsTarget(mapping); len(findings) != 1 || findings[0].Severity != SeverityHigh || findings[0].Evidence[3].Value != "E456" {
+		t.Fatal("Expected a high finding for the distribution without the alias", findings)
+	}
+}
+
+func TestCloudFrontAmbiguousDistribution(t *testing.T) {
+	mapping := ccloudfront.AwsMapping{
+		Distribution: ccloudfront.CloudFrontDistribution{Id: "E1"},
+		TargetDomain: ccloudfront.TargetAttributes{
+			DomainName: "www.example.com",
+			DistributionMatches: []ccloudfront.DistributionMatch{
+				{Id: "E1", Alias: "www.example.com", Selected: true},
+				{Id: "E2", Alias: "*.example.com", Wildcard: true},
+			},
+		},
+	}
+	if findings := evaluateCloudFrontAmbiguousDistribution(mapping); len(findings) != 0 {
+		t.Fatal("Expected no findings", findings)
+	}
+	mapping.TargetDomain.DistributionMatches[0] = ccloudfront.DistributionMatch{Id: "E1", Alias: "*.example.com", Wildcard: true, Selected: true}
+	if findings := evaluateCloudFrontAmbiguousDistribution(mapping); len(findings) != 1 || len(findings[0].Evidence) != 2 {
+		t.Fatal("Expected an ambiguous distribution finding", findings)
+	}
+}
+
+func TestS3OriginAccessControl(t *testing.T) {
+	distributionArn := "arn:aws:cloudfront::111122223333:distribution/E123"
+	oacStatement := func(condition iam.Condition) iam.PolicyDocument {
+		return iam.PolicyDocument{Statement: iam.Statements{{
+			Effect:    "Allow",
+			Action:    iam.Value{"s3:GetObject"},
+			Resource:  iam.Value{"arn:aws:s3:::bucket/*"},
+			Principal: &iam.Principal{Service: iam.Value{iam.CloudFrontServicePrincipal}},
+			Condition: condition,
+		}}}
+	}
+	tests := []struct {
+		name     string
+		policy   iam.PolicyDocument
+		findings int
+		severity Severity
+	}{
+		{"exact", oacStatement(iam.Condition{"StringEquals": {"AWS:SourceArn": {distributionArn}}}), 0, ""},
+		{"unrestricted", oacStatement(nil), 1, SeverityHigh},
+		{"other distribution", oacStatement(iam.Condition{"StringEquals": {"AWS:SourceArn": {"arn:aws:cloudfront::111122223333:distribution/EOTHER"}}}), 1, SeverityHigh},
+		{"wildcard", oacStatement(iam.Condition{"ArnLike": {"aws:SourceArn": {"arn:aws:cloudfront::111122223333:distribution/*"}}}), 1, SeverityMedium},
+		{"arn equals wildcard", oacStatement(iam.Condition{"ArnEquals": {"aws:SourceArn": {"arn:aws:cloudfront::111122223333:distribution/*"}}}), 1, SeverityMedium},
+		{"wildcard account", oacStatement(iam.Condition{"ArnLike": {"aws:SourceArn": {"arn:aws:cloudfront::*:distribution/*"}}}), 1, SeverityHigh},
+		{"literal wildcard", oacStatement(iam.Condition{"StringEquals": {"AWS:SourceArn": {"arn:aws:cloudfront::111122223333:distribution/*"}}}), 1, SeverityHigh},
+		{"other distributions", oacStatement(iam.Condition{"StringEquals": {"AWS:SourceArn": {distributionArn, "arn:aws:cloudfront::111122223333:distribution/EOTHER"}}}), 1, SeverityLow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapping := ccloudfront.AwsMapping{
+				Distribution: ccloudfront.CloudFrontDistribution{Id: "E123", Arn: distributionArn},
+				CloudFrontOrigins: []ccloudfront.CloudFrontOrigin{{
+					OriginType:            "s3-bucket",
+					OriginName:            "bucket",
+					OriginResourceExists:  true,
+					OriginBucketPolicy:    tt.policy,
+					OriginAccessControlId: "E2QWRUHEXAMPLE",
+					OriginAccessControl:   &ccloudfront.OriginAccessControl{Id: "E2QWRUHEXAMPLE", SigningBehavior: "always"},
+				}},
+			}
+			findings := evaluateS3OriginAccess(mapping)
+			if len(findings) != tt.findings || (tt.findings > 0 && findings[0].Severity != tt.severity) {
+				t.Fatal("Unexpected findings", findings)
+			}
+		})
+	}
+}
+
+func TestRoute53Delegation(t *testing.T) {
+	trace := &cdns.Trace{
+		Name:        "www.example.com.",
+		Zone:        "example.com.",
+		NameServers: []string{"ns-1.awsdns-01.org.", "ns1.old-provider.net."},
+		Servers: []cdns.NameServerCheck{
+			{NameServer: "ns-1.awsdns-01.org.", Authoritative: true, NameServers: []string{"ns-1.awsdns-01.org.", "ns-2.awsdns-02.com."}},
+			{NameServer: "ns1.old-provider.net.", Rcode: "REFUSED"},
+		},
+	}
+	tests := []struct {
+		name       string
+		zone       string
+		severities []Severity
+	}{
+		{"matching zone", "example.com.", []Severity{SeverityLow, SeverityMedium, SeverityHigh}},
+		{"subdomain zone", "www.example.com.", []Severity{SeverityLow, SeverityMedium, SeverityHigh}},
+		{"no hosted zone", "", []Severity{SeverityLow, SeverityMedium}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := ccloudfront.TargetAttributes{Delegation: trace, Route53Zone: tt.zone}
+			if tt.zone != "" {
+				target.Route53NameServers = []string{"ns-1.awsdns-01.org", "ns-2.awsdns-02.com"}
+			}
+			var severities []Severity
+			for _, f := range evaluateRoute53Delegation(ccloudfront.AwsMapping{TargetDomain: target}) {
+				severities = append(severities, f.Severity)
+			}
+			if !reflect.DeepEqual(severities, tt.severities) {
+				t.Fatal("Unexpected findings", severities)
+			}
+		})
+	}
+	if findings := evaluateRoute53Delegation(ccloudfront.AwsMapping{}); len(findings) != 0 {
+		t.Fatal("Expected no findings without a trace", findings)
+	}
+}
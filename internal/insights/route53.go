@@ -0,0 +1,87 @@
+package insights
+
+import (
+	"strings"
+
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
+)
+
+func init() {
+	Default.MustRegister(rule{
+		id:          "route53-delegation",
+		description: "The hosted zone should be delegated to its Route53 name servers, which should all answer authoritatively",
+		evaluate:    evaluateRoute53Delegation,
+	})
+}
+
+var delegationProblems = map[string]struct {
+	title       string
+	severity    Severity
+	remediation string
+}{
+	cdns.ProblemNotDelegated: {
+		title:       "Hosted zone is not delegated",
+		severity:    SeverityHigh,
+		remediation: "Add the NS records of the hosted zone's delegation set to the parent zone, the records of the hosted zone are not used until then",
+	},
+	cdns.ProblemMismatch: {
+		title:       "Delegation does not match the hosted zone",
+		severity:    SeverityHigh,
+		remediation: "Set the NS records of the parent zone, or the name servers at the registrar, to the hosted zone's delegation set",
+	},
+	cdns.ProblemLame: {
+		title:       "Lame delegation",
+		severity:    SeverityMedium,
+		remediation: "Remove the name server from the delegation, or serve the zone on it",
+	},
+	cdns.ProblemInconsistent: {
+		title:       "Zone NS records differ from the delegation",
+		severity:    SeverityLow,
+		remediation: "Make the NS records of the zone apex match the NS records of the parent zone",
+	},
+}
+
+func evaluateRoute53Delegation(mapping ccloudfront.AwsMapping) []Finding {
+	trace := mapping.TargetDomain.Delegation
+	if trace == nil {
+		return nil
+	}
+	zone := mapping.TargetDomain.Route53Zone
+	nameServers := mapping.TargetDomain.Route53NameServers
+	if len(nameServers) == 0 {
+		// The delegation set is unknown, only the trace itself is checked
+		zone = ""
+	}
+	resource := trace.Zone
+	if zone != "" {
+		resource = zone
+	}
+
+	var findings []Finding
+	for _, p := range trace.Problems(zone, nameServers) {
+		kind := delegationProblems[p.Kind]
+		evidence := []Evidence{
+			{Name: "DelegatedZone", Value: trace.Zone},
+			{Name: "DelegatedNameServers", Value: strings.Join(trace.NameServers, ", ")},
+		}
+		if p.NameServer != "" {
+			evidence = append(evidence, Evidence{Name: "NameServer", Value: p.NameServer})
+		}
+		if zone != "" {
+			evidence = append(evidence,
+				Evidence{Name: "Route53Zone", Value: zone},
+				Evidence{Name: "Route53NameServers", Value: strings.Join(nameServers, ", ")},
+			)
+		}
+		findings = append(findings, Finding{
+			Title:       kind.title,
+			Severity:    kind.severity,
+			Resource:    resource,
+			Message:     p.Message,
+			Evidence:    evidence,
+			Remediation: kind.remediation,
+		})
+	}
+	return findings
+}
@@ -0,0 +1,245 @@
+package insights
+
+import (
+	"strconv"
+	"strings"
+
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+	"github.com/unfor19/columbus-app/internal/aws/service/iam"
+)
+
+func init() {
+	Default.MustRegister(rule{
+		id:          "s3-origin-website-hosting",
+		description: "An S3 bucket origin should have static website hosting disabled, an S3 website origin should have it enabled",
+		evaluate:    evaluateS3OriginWebsiteHosting,
+	})
+	Default.MustRegister(rule{
+		id:          "s3-origin-protection",
+		description: "An S3 origin should only be reachable through CloudFront",
+		evaluate:    evaluateS3OriginProtection,
+	})
+	Default.MustRegister(rule{
+		id:          "s3-origin-access",
+		description: "The bucket policy of an S3 origin should grant access to the origin access identity or to exactly the distribution that uses its origin access control",
+		evaluate:    evaluateS3OriginAccess,
+	})
+}
+
+func originEvidence(o ccloudfront.CloudFrontOrigin) []Evidence {
+	return []Evidence{
+		{Name: "OriginType", Value: o.OriginType},
+		{Name: "OriginName", Value: o.OriginName},
+		{Name: "OriginUrl", Value: o.OriginUrl},
+	}
+}
+
+func evaluateS3OriginWebsiteHosting(mapping ccloudfront.AwsMapping) []Finding {
+	var findings []Finding
+	for _, o := range mapping.CloudFrontOrigins {
+		if !o.OriginResourceExists {
+			// Covered by s3-origin-exists, the website configuration is unknown
+			continue
+		}
+		evidence := append(originEvidence(o), Evidence{Name: "OriginIsWebsite", Value: strconv.FormatBool(o.OriginIsWebsite)})
+		switch {
+		case o.OriginType == "s3-bucket" && o.OriginIsWebsite:
+			findings = append(findings, Finding{
+				Title:       "S3 origin has static website hosting enabled",
+				Severity:    SeverityMedium,
+				Resource:    o.OriginName,
+				Message:     "The bucket " + o.OriginName + " is served through its REST endpoint (S3OriginConfig) but also has static website hosting enabled, the website endpoint can be used to bypass CloudFront",
+				Evidence:    evidence,
+				Remediation: "Disable static website hosting on the bucket, or change the origin to the bucket's website endpoint if website features (redirects, index documents) are required",
+			})
+		case o.OriginType == "s3-website" && !o.OriginIsWebsite:
+			findings = append(findings, Finding{
+				Title:       "S3 website origin has static website hosting disabled",
+				Severity:    SeverityHigh,
+				Resource:    o.OriginName,
+				Message:     "The origin " + o.OriginUrl + " is the website endpoint of the bucket " + o.OriginName + " but static website hosting is disabled, CloudFront requests to this origin fail",
+				Evidence:    evidence,
+				Remediation: "Enable static website hosting with an index document on the bucket, or change the origin to the bucket's REST endpoint with an origin access identity",
+			})
+		}
+	}
+	return findings
+}
+
+const (
+	VerdictProtected          = "protected"
+	VerdictPubliclyBypassable = "publicly bypassable"
+	VerdictBroken             = "broken"
+)
+
+// evaluateS3OriginProtection combines the bucket policy and the responses of
+// CloudFront and of the origin itself. A protected origin answers 403 when
+// requested directly while CloudFront answers 200.
+func evaluateS3OriginProtection(mapping ccloudfront.AwsMapping) []Finding {
+	var findings []Finding
+	cloudFrontStatus := mapping.TargetDomain.UrlResponse.StatusCode
+	for _, o := range mapping.CloudFrontOrigins {
+		if !strings.HasPrefix(o.OriginType, "s3") || !o.OriginResourceExists {
+			continue
+		}
+		originStatus := o.OriginUrlResponse.StatusCode
+		oais := o.OriginBucketPolicy.CloudFrontOriginAccessIdentities()
+		sourceArns, _ := o.OriginBucketPolicy.CloudFrontSourceArns()
+		// Only the origin's own identity lets this distribution read it
+		grantsCloudFront := o.OriginBucketPolicy.AllowsCloudFrontDistribution(mapping.Distribution.Arn)
+		if o.OriginAccessIdentity != "" {
+			for _, id := range oais {
+				grantsCloudFront = grantsCloudFront || id == originAccessIdentityId(o.OriginAccessIdentity)
+			}
+		}
+		evidence := append(originEvidence(o),
+			Evidence{Name: "CloudFrontStatusCode", Value: strconv.Itoa(cloudFrontStatus)},
+			Evidence{Name: "OriginStatusCode", Value: strconv.Itoa(originStatus)},
+			Evidence{Name: "OriginBucketPolicyIsPublic", Value: strconv.FormatBool(o.OriginBucketPolicyIsPublic)},
+			Evidence{Name: "OriginAccessIdentities", Value: strings.Join(oais, ",")},
+			Evidence{Name: "CloudFrontSourceArns", Value: strings.Join(sourceArns, ",")},
+		)
+		anonymousAccess := ""
+		for _, evaluation := range o.OriginObjectAccess {
+			if evaluation.Principal == "anonymous" {
+				anonymousAccess = string(evaluation.Decision)
+				evidence = append(evidence, Evidence{Name: "AnonymousAccess", Value: anonymousAccess + " " + evaluation.Action + " " + evaluation.Resource})
+			}
+		}
+		f := Finding{
+			Resource: o.OriginName,
+			Evidence: evidence,
+		}
+		switch {
+		case cloudFrontStatus != 200:
+			f.Verdict = VerdictBroken
+			f.Title = "CloudFront fails to serve the S3 origin"
+			f.Severity = SeverityHigh
+			f.Message = "CloudFront responded with " + strconv.Itoa(cloudFrontStatus) + " instead of 200 for " + mapping.TargetDomain.DomainName
+			f.Remediation = "Make sure the requested object exists in " + o.OriginName + " and that the bucket policy grants CloudFront access to it"
+		case o.OriginBucketPolicyIsPublic || originStatus == 200 || anonymousAccess == string(iam.DecisionAllow):
+			f.Verdict = VerdictPubliclyBypassable
+			f.Title = "S3 origin is publicly accessible"
+			f.Severity = SeverityHigh
+			f.Message = "The bucket " + o.OriginName + " can be read directly, bypassing CloudFront and its WAF, caching and logging"
+			f.Remediation = "Remove public access from the bucket policy, enable S3 Block Public Access, and grant read access to the CloudFront origin access identity only"
+		case o.OriginType == "s3-bucket" && !grantsCloudFront:
+			f.Verdict = VerdictBroken
+			f.Title = "Bucket policy does not grant CloudFront access"
+			f.Severity = SeverityHigh
+			f.Message = "The bucket policy of " + o.OriginName + " allows neither an origin access identity nor the distribution's origin access control to get objects, CloudFront responses are served from cache or fail"
+			f.Remediation = "Add a bucket policy statement that allows s3:GetObject to the distribution's origin access control or origin access identity"
+		case originStatus == 403:
+			f.Verdict = VerdictProtected
+			f.Title = "S3 origin is protected behind CloudFront"
+			f.Severity = SeverityInfo
+			f.Message = "The bucket " + o.OriginName + " denies direct requests and is served by CloudFront"
+		default:
+			f.Verdict = VerdictBroken
+			f.Title = "S3 origin responded unexpectedly"
+			f.Severity = SeverityMedium
+			f.Message = "Requesting " + o.OriginUrl + " directly responded with " + strconv.Itoa(originStatus) + " instead of 403"
+			f.Remediation = "Check that the origin domain name points to the right bucket and region"
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// originAccessIdentityId extracts EABC0KIJFBSUUS from
+// origin-access-identity/cloudfront/EABC0KIJFBSUUS
+func originAccessIdentityId(originAccessIdentity string) string {
+	parts := strings.Split(originAccessIdentity, "/")
+	return parts[len(parts)-1]
+}
+
+func evaluateS3OriginAccess(mapping ccloudfront.AwsMapping) []Finding {
+	var findings []Finding
+	distributionArn := mapping.Distribution.Arn
+	for _, o := range mapping.CloudFrontOrigins {
+		if o.OriginType != "s3-bucket" || !o.OriginResourceExists {
+			continue
+		}
+		policy := o.OriginBucketPolicy
+		oais := policy.CloudFrontOriginAccessIdentities()
+		sourceArns, unrestricted := policy.CloudFrontSourceArns()
+		evidence := append(originEvidence(o),
+			Evidence{Name: "DistributionArn", Value: distributionArn},
+			Evidence{Name: "OriginAccessIdentity", Value: o.OriginAccessIdentity},
+			Evidence{Name: "OriginAccessControlId", Value: o.OriginAccessControlId},
+			Evidence{Name: "OriginAccessIdentities", Value: strings.Join(oais, ",")},
+			Evidence{Name: "CloudFrontSourceArns", Value: strings.Join(sourceArns, ",")},
+		)
+		finding := func(severity Severity, title string, message string, remediation string) {
+			findings = append(findings, Finding{
+				Title:       title,
+				Severity:    severity,
+				Resource:    o.OriginName,
+				Message:     message,
+				Evidence:    evidence,
+				Remediation: remediation,
+			})
+		}
+
+		if o.OriginAccessIdentity != "" {
+			oai := originAccessIdentityId(o.OriginAccessIdentity)
+			granted := false
+			for _, id := range oais {
+				granted = granted || id == oai
+			}
+			if !granted {
+				finding(SeverityHigh, "Bucket policy does not grant the origin access identity",
+					"The origin uses the origin access identity "+oai+" but the bucket policy of "+o.OriginName+" does not allow it to get objects",
+					"Allow s3:GetObject to "+iam.OriginAccessIdentityArn(oai)+" in the bucket policy, or migrate the origin to an origin access control")
+			}
+		}
+
+		if o.OriginAccessControlId == "" {
+			continue
+		}
+		if o.OriginAccessControl == nil {
+			finding(SeverityMedium, "Origin access control could not be read",
+				"The origin uses the origin access control "+o.OriginAccessControlId+" but its configuration could not be read",
+				"Allow cloudfront:GetOriginAccessControl for the credentials used by Columbus")
+		} else if o.OriginAccessControl.SigningBehavior == "never" {
+			finding(SeverityHigh, "Origin access control does not sign requests",
+				"The origin access control "+o.OriginAccessControl.Name+" never signs requests, CloudFront reaches "+o.OriginName+" anonymously",
+				"Set the signing behavior of the origin access control to always")
+		}
+		switch {
+		case unrestricted:
+			finding(SeverityHigh, "Bucket policy allows every CloudFront distribution",
+				"The bucket policy of "+o.OriginName+" allows "+iam.CloudFrontServicePrincipal+" without an aws:SourceArn condition, any distribution in any AWS account can read the bucket",
+				"Add a StringEquals condition on AWS:SourceArn with the value "+distributionArn)
+		case !policy.AllowsCloudFrontDistribution(distributionArn):
+			finding(SeverityHigh, "Bucket policy does not grant the distribution",
+				"The origin uses an origin access control but the bucket policy of "+o.OriginName+" does not allow the distribution "+distributionArn+" to get objects",
+				"Allow s3:GetObject to "+iam.CloudFrontServicePrincipal+" with a StringEquals condition on AWS:SourceArn with the value "+distributionArn)
+		default:
+			wildcards := map[string]bool{}
+			for _, pattern := range policy.CloudFrontWildcardSourceArns() {
+				wildcards[pattern] = true
+				// A wildcard account allows the distributions of every account
+				severity := SeverityMedium
+				if parts := strings.SplitN(pattern, ":", 6); len(parts) != 6 || strings.ContainsAny(parts[4], "*?") {
+					severity = SeverityHigh
+				}
+				finding(severity, "Bucket policy allows distributions by a wildcard",
+					"The bucket policy of "+o.OriginName+" allows "+pattern+" to get objects, which matches other distributions than "+distributionArn,
+					"Replace the wildcard with a StringEquals condition on AWS:SourceArn with the value "+distributionArn)
+			}
+			var others []string
+			for _, arn := range sourceArns {
+				if arn != distributionArn && !wildcards[arn] {
+					others = append(others, arn)
+				}
+			}
+			if len(others) > 0 {
+				finding(SeverityLow, "Bucket policy grants other distributions",
+					"Besides "+distributionArn+", the bucket policy of "+o.OriginName+" allows "+strings.Join(others, ", ")+" to get objects",
+					"Remove the distributions that should not serve this bucket from the aws:SourceArn condition")
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,253 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+var (
+	ErrNotFound  = errors.New("job not found")
+	ErrQueueFull = errors.New("job queue is full")
+	ErrFinished  = errors.New("job already finished")
+	ErrShutdown  = errors.New("job manager is shutting down")
+)
+
+// Runner runs a job and reports each pipeline stage through progress. It
+// must return promptly once ctx is cancelled. The result it returns with an
+// error is kept as the job's partial result.
+type Runner func(ctx context.Context, progress func(stage string)) (interface{}, error)
+
+type Job struct {
+	Id         string
+	RequestUrl string
+	Status     Status
+	Stage      string
+	Progress   []string
+	Result     interface{} `json:",omitempty"`
+	Error      string      `json:",omitempty"`
+	CreatedAt  time.Time
+	StartedAt  *time.Time `json:",omitempty"`
+	FinishedAt *time.Time `json:",omitempty"`
+
+	run    Runner
+	cancel context.CancelFunc
+}
+
+func (j *Job) finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCancelled
+}
+
+func (j *Job) snapshot() Job {
+	s := *j
+	s.Progress = append([]string(nil), j.Progress...)
+	s.run = nil
+	s.cancel = nil
+	return s
+}
+
+// Manager runs jobs on a bounded pool of workers. Jobs that finished
+// more than retention ago are forgotten on the next Submit.
+type Manager struct {
+	retention time.Duration
+	queue     chan *Job
+	ctx       context.Context
+	stop      context.CancelFunc
+	wg        sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewManager(workers int, queueSize int, retention time.Duration) *Manager {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		retention: retention,
+		queue:     make(chan *Job, queueSize),
+		ctx:       ctx,
+		stop:      stop,
+		jobs:      map[string]*Job{},
+	}
+	for i := 0; i < workers; i++ {
+		m.wg.Add(1)
+		go m.work()
+	}
+	return m
+}
+
+func newJobId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Println("Failed to generate job id:", err)
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// Submit enqueues run, the job of requestUrl with the configuration of the
+// request that submitted it, and returns its initial state.
+func (m *Manager) Submit(requestUrl string, run Runner) (Job, error) {
+	if m.ctx.Err() != nil {
+		return Job{}, ErrShutdown
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+	j := &Job{
+		Id:         newJobId(),
+		RequestUrl: requestUrl,
+		Status:     StatusQueued,
+		Stage:      "queued",
+		CreatedAt:  time.Now().UTC(),
+		run:        run,
+	}
+	select {
+	case m.queue <- j:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	m.jobs[j.Id] = j
+	return j.snapshot(), nil
+}
+
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// Cancel stops a queued or running job. A queued job is marked as
+// cancelled right away, a running job is cancelled once its runner returns.
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	if j.finished() {
+		return j.snapshot(), ErrFinished
+	}
+	if j.Status == StatusQueued {
+		m.finish(j, StatusCancelled, nil, context.Canceled)
+	} else if j.cancel != nil {
+		j.cancel()
+	}
+	return j.snapshot(), nil
+}
+
+// Shutdown cancels all running jobs and waits for the workers to exit.
+func (m *Manager) Shutdown() {
+	m.stop()
+	m.wg.Wait()
+}
+
+func (m *Manager) work() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case j := <-m.queue:
+			m.run(j)
+		}
+	}
+}
+
+func (m *Manager) run(j *Job) {
+	m.mu.Lock()
+	if j.finished() {
+		m.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+	now := time.Now().UTC()
+	j.Status = StatusRunning
+	j.StartedAt = &now
+	j.cancel = cancel
+	m.mu.Unlock()
+
+	log.Println("Starting job", j.Id, j.RequestUrl)
+	result, err := m.runSafely(ctx, j)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case err != nil && ctx.Err() != nil:
+		m.finish(j, StatusCancelled, result, err)
+	case err != nil:
+		m.finish(j, StatusFailed, result, err)
+	default:
+		m.finish(j, StatusSucceeded, result, nil)
+	}
+	log.Println("Finished job", j.Id, j.Status)
+}
+
+// runSafely keeps a panicking runner from taking down the whole server.
+func (m *Manager) runSafely(ctx context.Context, j *Job) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Job", j.Id, "panicked:", r)
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return j.run(ctx, func(stage string) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		j.Stage = stage
+		j.Progress = append(j.Progress, stage)
+	})
+}
+
+// finish must be called with m.mu held.
+func (m *Manager) finish(j *Job, status Status, result interface{}, err error) {
+	now := time.Now().UTC()
+	j.Status = status
+	j.Stage = string(status)
+	j.Result = result
+	if err != nil {
+		j.Error = err.Error()
+	}
+	j.FinishedAt = &now
+	j.run = nil
+	j.cancel = nil
+}
+
+// prune must be called with m.mu held.
+func (m *Manager) prune() {
+	if m.retention <= 0 {
+		return
+	}
+	deadline := time.Now().UTC().Add(-m.retention)
+	for id, j := range m.jobs {
+		if j.finished() && j.FinishedAt.Before(deadline) {
+			delete(m.jobs, id)
+		}
+	}
+}
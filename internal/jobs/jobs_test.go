@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func waitForStatus(t *testing.T, m *Manager, id string, status Status) Job {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Job", id, "did not reach status", status)
+	return Job{}
+}
+
+func TestJobSucceeds(t *testing.T) {
+	m := NewManager(2, 10, time.Hour)
+	defer m.Shutdown()
+
+	job, err := m.Submit("https://example.com", func(ctx context.Context, progress func(string)) (interface{}, error) {
+		progress("first")
+		progress("second")
+		return "result", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job = waitForStatus(t, m, job.Id, StatusSucceeded)
+	if job.Result != "result" || job.RequestUrl != "https://example.com" {
+		t.Fatal("Unexpected result", job.Result, job.RequestUrl)
+	}
+	if len(job.Progress) != 2 || job.Progress[1] != "second" {
+		t.Fatal("Unexpected progress", job.Progress)
+	}
+}
+
+func TestJobFails(t *testing.T) {
+	m := NewManager(1, 10, time.Hour)
+	defer m.Shutdown()
+
+	job, _ := m.Submit("https://example.com", func(ctx context.Context, progress func(string)) (interface{}, error) {
+		progress("first")
+		return "partial", errors.New("boom")
+	})
+	job = waitForStatus(t, m, job.Id, StatusFailed)
+	if job.Error != "boom" {
+		t.Fatal("Unexpected error", job.Error)
+	}
+	if job.Result != "partial" {
+		t.Fatal("Expected the partial result, got", job.Result)
+	}
+}
+
+func TestJobPanics(t *testing.T) {
+	m := NewManager(1, 10, time.Hour)
+	defer m.Shutdown()
+
+	job, _ := m.Submit("https://example.com", func(ctx context.Context, progress func(string)) (interface{}, error) {
+		var origins []string
+		return origins[1], nil
+	})
+	waitForStatus(t, m, job.Id, StatusFailed)
+}
+
+func TestCancelRunningAndQueuedJobs(t *testing.T) {
+	started := make(chan struct{})
+	run := func(ctx context.Context, progress func(string)) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	m := NewManager(1, 10, time.Hour)
+	defer m.Shutdown()
+
+	running, _ := m.Submit("https://running.example.com", run)
+	<-started
+	queued, _ := m.Submit("https://queued.example.com", run)
+
+	if _, err := m.Cancel(queued.Id); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, m, queued.Id, StatusCancelled)
+
+	if _, err := m.Cancel(running.Id); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, m, running.Id, StatusCancelled)
+
+	if _, err := m.Cancel(running.Id); err != ErrFinished {
+		t.Fatal("Expected", ErrFinished, "got", err)
+	}
+	if _, err := m.Cancel("missing"); err != ErrNotFound {
+		t.Fatal("Expected", ErrNotFound, "got", err)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	run := func(ctx context.Context, progress func(string)) (interface{}, error) {
+		select {
+		case <-block:
+		case <-ctx.Done():
+		}
+		return nil, nil
+	}
+	m := NewManager(1, 1, time.Hour)
+	defer m.Shutdown()
+	defer close(block)
+
+	first, _ := m.Submit("https://1.example.com", run)
+	waitForStatus(t, m, first.Id, StatusRunning)
+	if _, err := m.Submit("https://2.example.com", run); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Submit("https://3.example.com", run); err != ErrQueueFull {
+		t.Fatal("Expected", ErrQueueFull, "got", err)
+	}
+}
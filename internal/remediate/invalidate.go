@@ -0,0 +1,215 @@
+package remediate
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+	cs3 "github.com/unfor19/columbus-app/internal/aws/service/s3"
+	"github.com/unfor19/columbus-app/pkg/traffic"
+)
+
+var ErrNoDistribution = errors.New("DistributionId is required")
+var ErrNoPaths = errors.New("at least one path is required")
+var ErrRelativePath = errors.New("paths must start with /")
+
+type InvalidationRequest struct {
+	DistributionId string
+	Paths          []string
+	// DryRun defaults to true, the invalidation is only created when it is
+	// explicitly set to false.
+	DryRun *bool
+	// Target is set by the server from the exploration that selected the
+	// distribution, never by the client. When the paths invalidate it, the
+	// ETag served by CloudFront is compared with the ETag of the object in S3
+	// once the invalidation completes.
+	Target *ETagTarget `json:"-"`
+}
+
+// ETagTarget is an explored URL of a distribution and the S3 object that its
+// route resolved to
+type ETagTarget struct {
+	RequestUrl string
+	Bucket     string
+	Key        string
+}
+
+type InvalidationResult struct {
+	DistributionId string
+	Paths          []string
+	DryRun         bool
+	InvalidationId string `json:",omitempty"`
+	Status         string
+	OriginETag     string `json:",omitempty"`
+	ServedETag     string `json:",omitempty"`
+	Converged      bool
+}
+
+type PollOptions struct {
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+func DefaultPollOptions() PollOptions {
+	return PollOptions{
+		Interval: 10 * time.Second,
+		Timeout:  15 * time.Minute,
+	}
+}
+
+// IsDryRun reports whether the invalidation is only described, not created
+func (r InvalidationRequest) IsDryRun() bool {
+	return r.DryRun == nil || *r.DryRun
+}
+
+// NewETagTarget returns the explored URL of mapping and the S3 object that its
+// route resolved to, ok is false when the route does not end in an S3 origin.
+// The URL keeps the scheme and port of the explored URL, without its query.
+func NewETagTarget(mapping ccloudfront.AwsMapping) (t ETagTarget, ok bool) {
+	route := mapping.Route
+	u, err := url.Parse(mapping.TargetDomain.RequestUrl)
+	if route == nil || err != nil || u.Host == "" || !servedBy(mapping.Distribution, u.Hostname()) {
+		return t, false
+	}
+	for _, o := range mapping.CloudFrontOrigins {
+		if o.OriginId != route.OriginId || !strings.HasPrefix(o.OriginType, "s3") || o.OriginName == "" || route.ObjectKey == "" {
+			continue
+		}
+		return ETagTarget{
+			RequestUrl: u.Scheme + "://" + u.Host + route.Path,
+			Bucket:     o.OriginName,
+			Key:        route.ObjectKey,
+		}, true
+	}
+	return t, false
+}
+
+// servedBy reports whether host is the domain name or an alias of d
+func servedBy(d ccloudfront.CloudFrontDistribution, host string) bool {
+	for _, name := range append([]string{d.DomainName}, d.Aliases...) {
+		if ccloudfront.MatchesAlias(name, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// path returns the path of the target as CloudFront invalidates it
+func (t ETagTarget) path() string {
+	u, err := url.Parse(t.RequestUrl)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// comparesETags reports whether the target is invalidated by the paths, a
+// path invalidates the paths it matches when it ends with *
+func (r InvalidationRequest) comparesETags() bool {
+	if r.Target == nil {
+		return false
+	}
+	path := r.Target.path()
+	for _, p := range r.Paths {
+		if p == path || strings.HasSuffix(p, "*") && strings.HasPrefix(path, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func Validate(r InvalidationRequest) error {
+	if r.DistributionId == "" {
+		return ErrNoDistribution
+	}
+	if len(r.Paths) == 0 {
+		return ErrNoPaths
+	}
+	for _, path := range r.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return ErrRelativePath
+		}
+	}
+	return nil
+}
+
+// Invalidate creates a CloudFront invalidation for the requested paths with
+// cfg, the configuration of the distribution's account, waits for it to
+// complete and for the served ETag to match the one in S3. The bucket is read
+// with the configuration that bucketConfig returns for it.
+func Invalidate(ctx context.Context, cfg aws.Config, bucketConfig ccloudfront.BucketConfigFunc, r InvalidationRequest, opts PollOptions) (InvalidationResult, error) {
+	result := InvalidationResult{
+		DistributionId: r.DistributionId,
+		Paths:          r.Paths,
+		DryRun:         r.IsDryRun(),
+	}
+	if err := Validate(r); err != nil {
+		return result, err
+	}
+	if result.DryRun {
+		result.Status = "DryRun"
+		log.Println("Dry run, skipping invalidation of", result.Paths, "in distribution", r.DistributionId)
+		return result, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	// The bucket is checked first, so a bucket that cannot be read does not
+	// leave an invalidation behind whose result cannot be verified
+	var s3Cfg aws.Config
+	if r.comparesETags() {
+		region, err := cs3.GetS3BucketRegion(ctx, bucketConfig(ctx, r.Target.Bucket, ""), r.Target.Bucket)
+		if err != nil {
+			return result, err
+		}
+		s3Cfg = bucketConfig(ctx, r.Target.Bucket, region)
+	}
+	invalidationId, err := ccloudfront.CreateInvalidation(ctx, cfg, r.DistributionId, result.Paths)
+	if err != nil {
+		return result, err
+	}
+	result.InvalidationId = invalidationId
+	result.Status = "InProgress"
+	if err := ccloudfront.WaitForInvalidation(ctx, cfg, r.DistributionId, invalidationId, opts.Interval); err != nil {
+		return result, err
+	}
+	result.Status = ccloudfront.InvalidationStatusCompleted
+	if !r.comparesETags() {
+		return result, nil
+	}
+	err = waitForETags(ctx, s3Cfg, *r.Target, &result, opts.Interval)
+	return result, err
+}
+
+// waitForETags polls until the ETag served by CloudFront matches the one in S3,
+// edge locations may keep serving the previous object for a short while after
+// the invalidation completes. cfg is the configuration of the bucket.
+func waitForETags(ctx context.Context, cfg aws.Config, t ETagTarget, result *InvalidationResult, interval time.Duration) error {
+	for {
+		originETag, err := cs3.GetS3ObjectETag(ctx, cfg, t.Bucket, t.Key)
+		if err != nil {
+			return err
+		}
+		result.OriginETag = originETag
+		resp, err := traffic.GetRequestUrlResponse(ctx, t.RequestUrl)
+		if err != nil {
+			return err
+		}
+		result.ServedETag = traffic.GetResponseETag(resp)
+		log.Println("Origin ETag:", result.OriginETag, "Served ETag:", result.ServedETag)
+		if result.OriginETag == result.ServedETag {
+			result.Converged = true
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package remediate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+)
+
+func TestInvalidateDefaultsToDryRun(t *testing.T) {
+	req := InvalidationRequest{DistributionId: "E123", Paths: []string{"/index.html"}}
+	result, err := Invalidate(context.Background(), aws.Config{}, nil, req, DefaultPollOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.DryRun || result.Status != "DryRun" || result.InvalidationId != "" {
+		t.Fatal("Expected a dry run, got", result)
+	}
+	if len(result.Paths) != 1 || result.Paths[0] != "/index.html" {
+		t.Fatal("Unexpected paths", result.Paths)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(InvalidationRequest{Paths: []string{"/*"}}); err != ErrNoDistribution {
+		t.Fatal("Expected", ErrNoDistribution, "got", err)
+	}
+	if err := Validate(InvalidationRequest{DistributionId: "E123"}); err != ErrNoPaths {
+		t.Fatal("Expected", ErrNoPaths, "got", err)
+	}
+	if err := Validate(InvalidationRequest{DistributionId: "E123", Paths: []string{"/", "index.html"}}); err != ErrRelativePath {
+		t.Fatal("Expected", ErrRelativePath, "got", err)
+	}
+}
+
+func testMapping() ccloudfront.AwsMapping {
+	return ccloudfront.AwsMapping{
+		Distribution: ccloudfront.CloudFrontDistribution{Id: "E123", DomainName: "d123.cloudfront.net", Aliases: []string{"*.example.com"}},
+		CloudFrontOrigins: []ccloudfront.CloudFrontOrigin{
+			{OriginId: "api", OriginType: "apigw", OriginName: "api"},
+			{OriginId: "s3-bucket", OriginType: "s3-bucket", OriginName: "bucket", OriginPath: "/production"},
+		},
+		Route:        &ccloudfront.CloudFrontRoute{Path: "/", OriginId: "s3-bucket", ObjectKey: "production/index.html"},
+		TargetDomain: ccloudfront.TargetAttributes{DomainName: "dev.example.com", RequestUrl: "http://dev.example.com:8080/?v=1"},
+	}
+}
+
+func TestNewETagTarget(t *testing.T) {
+	// The URL keeps the scheme and port of the explored URL, the object is
+	// the one the route resolved to
+	target, ok := NewETagTarget(testMapping())
+	expected := ETagTarget{RequestUrl: "http://dev.example.com:8080/", Bucket: "bucket", Key: "production/index.html"}
+	if !ok || target != expected {
+		t.Fatal("Expected", expected, "got", target, ok)
+	}
+
+	// A host that is not one of the distribution's aliases
+	mapping := testMapping()
+	mapping.TargetDomain.RequestUrl = "https://attacker.example.org/"
+	if target, ok := NewETagTarget(mapping); ok {
+		t.Fatal("Expected no target for a host outside the aliases, got", target)
+	}
+
+	// A route to another than an S3 origin
+	mapping = testMapping()
+	mapping.Route.OriginId = "api"
+	if target, ok := NewETagTarget(mapping); ok {
+		t.Fatal("Expected no target for an API Gateway origin, got", target)
+	}
+
+	mapping.Route = nil
+	if target, ok := NewETagTarget(mapping); ok {
+		t.Fatal("Expected no target without a route, got", target)
+	}
+}
+
+func TestComparesETags(t *testing.T) {
+	target := &ETagTarget{RequestUrl: "https://dev.example.com/docs/index.html", Bucket: "bucket", Key: "docs/index.html"}
+	tests := []struct {
+		name     string
+		paths    []string
+		target   *ETagTarget
+		expected bool
+	}{
+		{"exact path", []string{"/docs/index.html"}, target, true},
+		{"wildcard", []string{"/docs/*"}, target, true},
+		{"all paths", []string{"/*"}, target, true},
+		{"other path", []string{"/app.js", "/docs/other/*"}, target, false},
+		{"no target", []string{"/*"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := InvalidationRequest{DistributionId: "E123", Paths: tt.paths, Target: tt.target}
+			if compares := req.comparesETags(); compares != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, compares)
+			}
+		})
+	}
+}
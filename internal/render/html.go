@@ -0,0 +1,52 @@
+package render
+
+import (
+	"html/template"
+	"io"
+)
+
+// The report is a single file without external assets, so it can be saved or
+// attached as is.
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 72em; color: #24292f; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+th, td { border: 1px solid #d0d7de; padding: 0.4em 0.8em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+code { background: #f6f8fa; padding: 0.1em 0.3em; word-break: break-all; }
+.finding { border-left: 4px solid #d0d7de; padding: 0.2em 1em; margin-bottom: 1em; }
+.severity-info { border-color: #0969da; }
+.severity-low { border-color: #bf8700; }
+.severity-medium { border-color: #bc4c00; }
+.severity-high { border-color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .MaxSeverity}}<p><strong>Highest severity:</strong> {{.MaxSeverity}}</p>{{end}}
+{{range .Sections}}
+<h2>{{.Title}}</h2>
+{{if .Rows}}<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>{{else}}<p>{{.Empty}}</p>{{end}}
+{{end}}
+{{range .Findings}}
+<div class="finding severity-{{.Severity}}">
+<h3>[{{.Severity}}] {{.Title}}</h3>
+<p>{{.Message}}</p>
+{{if .Evidence}}<ul>{{range .Evidence}}<li>{{.Name}}: <code>{{.Value}}</code></li>{{end}}</ul>{{end}}
+{{if .Remediation}}<p><strong>Remediation:</strong> {{.Remediation}}</p>{{end}}
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+func renderHTML(w io.Writer, r report) error {
+	return htmlTemplate.Execute(w, r)
+}
@@ -0,0 +1,48 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// markdownCell escapes the characters that break a Markdown table cell
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+func renderMarkdown(w io.Writer, r report) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# %s\n", r.Title)
+	if r.MaxSeverity != "" {
+		fmt.Fprintf(b, "\n**Highest severity:** %s\n", r.MaxSeverity)
+	}
+	for _, s := range r.Sections {
+		fmt.Fprintf(b, "\n## %s\n\n", s.Title)
+		if len(s.Rows) == 0 {
+			fmt.Fprintln(b, s.Empty)
+			continue
+		}
+		fmt.Fprintf(b, "| %s |\n", strings.Join(s.Header, " | "))
+		fmt.Fprintf(b, "|%s\n", strings.Repeat(" --- |", len(s.Header)))
+		for _, row := range s.Rows {
+			cells := make([]string, len(row))
+			for i, cell := range row {
+				cells[i] = markdownCell(cell)
+			}
+			fmt.Fprintf(b, "| %s |\n", strings.Join(cells, " | "))
+		}
+	}
+	for _, f := range r.Findings {
+		fmt.Fprintf(b, "\n### [%s] %s\n\n%s\n", f.Severity, f.Title, f.Message)
+		for _, e := range f.Evidence {
+			fmt.Fprintf(b, "- %s: `%s`\n", e.Name, e.Value)
+		}
+		if f.Remediation != "" {
+			fmt.Fprintf(b, "\n**Remediation:** %s\n", f.Remediation)
+		}
+	}
+	return b.Flush()
+}
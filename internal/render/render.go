@@ -0,0 +1,149 @@
+// Package render writes the result of an exploration in the formats that
+// Columbus serves, JSON, YAML, an aligned table for terminals, a Markdown
+// summary for tickets, a self-contained HTML report and the topology graph
+// in DOT or Mermaid.
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/unfor19/columbus-app/internal/explorer"
+	"github.com/unfor19/columbus-app/internal/graph"
+	"gopkg.in/yaml.v2"
+)
+
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatYAML     Format = "yaml"
+	FormatTable    Format = "table"
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+	FormatDOT      Format = "dot"
+	FormatMermaid  Format = "mermaid"
+)
+
+var Formats = []Format{FormatJSON, FormatYAML, FormatTable, FormatMarkdown, FormatHTML, FormatDOT, FormatMermaid}
+
+var formatAliases = map[string]Format{
+	"yml": FormatYAML,
+	"md":  FormatMarkdown,
+	"txt": FormatTable,
+	"gv":  FormatDOT,
+	"mmd": FormatMermaid,
+}
+
+var mediaTypes = map[string]Format{
+	"application/json":   FormatJSON,
+	"application/yaml":   FormatYAML,
+	"application/x-yaml": FormatYAML,
+	"text/yaml":          FormatYAML,
+	"text/plain":         FormatTable,
+	"text/markdown":      FormatMarkdown,
+	"text/html":          FormatHTML,
+	"text/vnd.graphviz":  FormatDOT,
+	"text/vnd.mermaid":   FormatMermaid,
+}
+
+func ParseFormat(s string) (Format, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if f, ok := formatAliases[s]; ok {
+		return f, nil
+	}
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown format %q, expected one of %v", s, Formats)
+}
+
+// Negotiate picks the format of the media type with the highest quality in
+// an Accept header, JSON is the default when nothing else matches.
+func Negotiate(accept string) Format {
+	format, quality := FormatJSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		f, ok := mediaTypes[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			q = v
+		}
+		if q > quality {
+			format, quality = f, q
+		}
+	}
+	return format
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatYAML:
+		return "application/yaml; charset=utf-8"
+	case FormatTable:
+		return "text/plain; charset=utf-8"
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatDOT:
+		return "text/vnd.graphviz; charset=utf-8"
+	case FormatMermaid:
+		return "text/vnd.mermaid; charset=utf-8"
+	}
+	return "application/json; charset=utf-8"
+}
+
+// Render writes result to w in the given format
+func Render(w io.Writer, f Format, result explorer.Result) error {
+	switch f {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case FormatYAML:
+		return renderYAML(w, result)
+	case FormatTable:
+		return renderTable(w, summarize(result))
+	case FormatMarkdown:
+		return renderMarkdown(w, summarize(result))
+	case FormatHTML:
+		return renderHTML(w, summarize(result))
+	case FormatDOT:
+		return graph.New(result.AwsMapping).WriteDOT(w)
+	case FormatMermaid:
+		return graph.New(result.AwsMapping).WriteMermaid(w)
+	}
+	return fmt.Errorf("unknown format %q", f)
+}
+
+// renderYAML goes through JSON so the YAML keys and values are the same as
+// in the JSON response, such as single policy values that are strings.
+func renderYAML(w io.Writer, result explorer.Result) error {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	var document yaml.MapSlice
+	if err := yaml.Unmarshal(b, &document); err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(document)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
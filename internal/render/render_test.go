@@ -0,0 +1,101 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+	"github.com/unfor19/columbus-app/internal/aws/service/iam"
+	"github.com/unfor19/columbus-app/internal/explorer"
+	"github.com/unfor19/columbus-app/internal/insights"
+)
+
+func testResult() explorer.Result {
+	return explorer.Result{
+		AwsMapping: ccloudfront.AwsMapping{
+			Distribution: ccloudfront.CloudFrontDistribution{
+				Id:         "E1A2B3C4D5E6F7",
+				DomainName: "d111111abcdef8.cloudfront.net",
+				Aliases:    []string{"dev.sokker.info"},
+			},
+			CloudFrontOrigins: []ccloudfront.CloudFrontOrigin{{
+				OriginType:           "s3",
+				OriginName:           "dev.sokker.info",
+				OriginResourceExists: true,
+				OriginAccessIdentity: "origin-access-identity/cloudfront/E2QWRUHAPOMQZL",
+				OriginBucketPolicy: iam.PolicyDocument{Statement: iam.Statements{{
+					Effect: "Allow",
+					Action: iam.Value{"s3:GetObject"},
+				}}},
+			}},
+			TargetDomain: ccloudfront.TargetAttributes{
+				DomainName:    "dev.sokker.info",
+				TargetService: "CLOUDFRONT",
+			},
+		},
+		Findings: []insights.Finding{{
+			Insight:     "cloudfront-waf",
+			Title:       "No WAF | web ACL",
+			Severity:    insights.SeverityMedium,
+			Resource:    "E1A2B3C4D5E6F7",
+			Message:     "The distribution is not protected by <WAF>",
+			Remediation: "Associate a web ACL",
+		}},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for input, expected := range map[string]Format{"json": FormatJSON, "YAML": FormatYAML, "yml": FormatYAML, "md": FormatMarkdown, "html": FormatHTML} {
+		f, err := ParseFormat(input)
+		if err != nil || f != expected {
+			t.Errorf("ParseFormat(%q) = %q, %v, expected %q", input, f, err, expected)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]Format{
+		"":                                    FormatJSON,
+		"*/*":                                 FormatJSON,
+		"text/html,application/xhtml+xml":     FormatHTML,
+		"application/yaml":                    FormatYAML,
+		"text/markdown;q=0.5, text/plain":     FormatTable,
+		"text/plain;q=0.2, text/markdown;q=1": FormatMarkdown,
+		"text/vnd.graphviz":                   FormatDOT,
+	}
+	for accept, expected := range tests {
+		if f := Negotiate(accept); f != expected {
+			t.Errorf("Negotiate(%q) = %q, expected %q", accept, f, expected)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		format   Format
+		contains []string
+	}{
+		{FormatJSON, []string{`"Findings": [`, `"Action": "s3:GetObject"`}},
+		{FormatYAML, []string{"Distribution:\n  Id: E1A2B3C4D5E6F7", "Action: s3:GetObject"}},
+		{FormatTable, []string{"ORIGINS", "OAI origin-access-identity/cloudfront/E2QWRUHAPOMQZL", "medium    cloudfront-waf"}},
+		{FormatMarkdown, []string{"## Findings", `No WAF \| web ACL`, "**Remediation:** Associate a web ACL"}},
+		{FormatHTML, []string{"<td>dev.sokker.info</td>", "&lt;WAF&gt;", `class="finding severity-medium"`}},
+		{FormatDOT, []string{`"cloudfront-distribution:E1A2B3C4D5E6F7" -> "origin:s3:dev.sokker.info"`}},
+		{FormatMermaid, []string{"flowchart LR", `|"routes-to"|`}},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		if err := Render(&b, test.format, testResult()); err != nil {
+			t.Fatalf("%s: %v", test.format, err)
+		}
+		for _, s := range test.contains {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("%s output does not contain %q:\n%s", test.format, s, b.String())
+			}
+		}
+	}
+}
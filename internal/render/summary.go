@@ -0,0 +1,206 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/unfor19/columbus-app/internal/explorer"
+	"github.com/unfor19/columbus-app/internal/insights"
+)
+
+// section is a titled table, the human readable formats render the same
+// sections so they always show the same information.
+type section struct {
+	Title  string
+	Header []string
+	Rows   [][]string
+	// Empty is shown instead of the table when there are no rows
+	Empty string
+}
+
+type report struct {
+	Title       string
+	MaxSeverity insights.Severity
+	Sections    []section
+	// Findings are detailed after the sections in Markdown and HTML
+	Findings []insights.Finding
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func originAccess(o explorer.Result, i int) string {
+	origin := o.CloudFrontOrigins[i]
+	var access []string
+	if origin.OriginAccessIdentity != "" {
+		access = append(access, "OAI "+origin.OriginAccessIdentity)
+	}
+	if origin.OriginAccessControlId != "" {
+		access = append(access, "OAC "+origin.OriginAccessControlId)
+	}
+	return orNone(strings.Join(access, ", "))
+}
+
+func summarize(result explorer.Result) report {
+	target := result.TargetDomain
+	r := report{
+		Title:       "Columbus - " + target.DomainName,
+		MaxSeverity: insights.MaxSeverity(result.Findings),
+		Findings:    result.Findings,
+	}
+
+	status := ""
+	if target.UrlResponse.StatusCode != 0 {
+		status = strconv.Itoa(target.UrlResponse.StatusCode)
+	}
+	// The most specific prefix, the others are in the JSON and YAML output
+	ipRange := ""
+	if len(target.TargetIpRanges) > 0 {
+		m := target.TargetIpRanges[0]
+		ipRange = fmt.Sprintf("%s (%s, %s)", m.Prefix, m.Service, m.Region)
+	}
+	var cnames []string
+	for _, record := range target.Resolution.CnameChain {
+		cnames = append(cnames, strings.TrimSuffix(record.Value, "."))
+	}
+	resolver := ""
+	if target.Resolution.Server != "" {
+		resolver = target.Resolution.Server + " (" + target.Resolution.Rcode + ")"
+	}
+	r.Sections = append(r.Sections, section{
+		Title:  "Domain",
+		Header: []string{"Field", "Value"},
+		Rows: [][]string{
+			{"Domain", orNone(target.DomainName)},
+			{"Registered domain", orNone(target.RegisteredName)},
+			{"Resolver", orNone(resolver)},
+			{"CNAME chain", orNone(strings.Join(cnames, " -> "))},
+			{"IP address", orNone(target.TargetIpAddress)},
+			{"Service", orNone(target.TargetService)},
+			{"AWS ip range", orNone(ipRange)},
+			{"Status code", orNone(status)},
+			{"ETag", orNone(target.EtagResponse)},
+			{"Route53 record", orNone(target.Route53Record)},
+			{"WAF", orNone(target.WafId)},
+		},
+	})
+
+	records := section{
+		Title:  "Route53 Records",
+		Header: []string{"Zone", "Name", "Type", "TTL", "Value", "Routing"},
+		Empty:  "No Route53 records",
+	}
+	for _, record := range target.Route53Records {
+		zone := record.ZoneName
+		if record.PrivateZone {
+			zone += " (private)"
+		}
+		ttl, value := "", strings.Join(record.Values, ", ")
+		if record.Ttl > 0 {
+			ttl = strconv.FormatInt(record.Ttl, 10)
+		}
+		if record.AliasTarget != nil {
+			value = "alias " + record.AliasTarget.DNSName
+		}
+		routing := record.RoutingPolicy
+		if record.SetIdentifier != "" {
+			routing += " " + record.SetIdentifier
+		}
+		records.Rows = append(records.Rows, []string{zone, record.Name, record.Type, orNone(ttl), orNone(value), routing})
+	}
+	r.Sections = append(r.Sections, records)
+
+	dnsTargets := section{
+		Title:  "DNS Targets",
+		Header: []string{"Source", "Record", "Domain name", "Distribution"},
+		Empty:  "No records point to CloudFront",
+	}
+	for _, t := range target.DnsTargets {
+		dnsTargets.Rows = append(dnsTargets.Rows, []string{t.Source, t.Record, t.DomainName, orNone(t.DistributionId)})
+	}
+	r.Sections = append(r.Sections, dnsTargets)
+
+	distribution := result.Distribution
+	matchedBy := ""
+	for _, m := range target.DistributionMatches {
+		if m.Selected {
+			matchedBy = m.Alias
+		}
+	}
+	r.Sections = append(r.Sections, section{
+		Title:  "Distribution",
+		Header: []string{"Field", "Value"},
+		Rows: [][]string{
+			{"Id", orNone(distribution.Id)},
+			{"Domain name", orNone(distribution.DomainName)},
+			{"Status", orNone(distribution.Status)},
+			{"Aliases", orNone(strings.Join(distribution.Aliases, ", "))},
+			{"Matched by", orNone(matchedBy)},
+		},
+	})
+
+	if route := result.Route; route != nil {
+		behavior := route.PathPattern
+		if route.Default {
+			behavior = "default (" + behavior + ")"
+		}
+		origin := route.OriginId
+		if route.OriginGroupId != "" {
+			origin += " (primary of " + route.OriginGroupId + ")"
+		}
+		r.Sections = append(r.Sections, section{
+			Title:  "Route",
+			Header: []string{"Field", "Value"},
+			Rows: [][]string{
+				{"Path", route.Path},
+				{"Cache behavior", behavior},
+				{"Skipped patterns", orNone(strings.Join(route.Skipped, ", "))},
+				{"Viewer protocol", orNone(route.ViewerProtocolPolicy)},
+				{"Origin", orNone(origin)},
+				{"Origin URL", orNone(route.OriginUrl)},
+				{"Origin path", orNone(route.OriginPath)},
+				{"Object key", orNone(route.ObjectKey)},
+			},
+		})
+	}
+
+	origins := section{
+		Title:  "Origins",
+		Header: []string{"Type", "Name", "Path", "Exists", "Website", "Access"},
+		Empty:  "No origins",
+	}
+	for i, o := range result.CloudFrontOrigins {
+		origins.Rows = append(origins.Rows, []string{
+			orNone(o.OriginType), orNone(o.OriginName), orNone(o.OriginPath),
+			yesNo(o.OriginResourceExists), yesNo(o.OriginIsWebsite), originAccess(result, i),
+		})
+	}
+	r.Sections = append(r.Sections, origins)
+
+	findings := section{
+		Title:  "Findings",
+		Header: []string{"Severity", "Insight", "Resource", "Title"},
+		Empty:  "No findings",
+	}
+	for _, f := range result.Findings {
+		title := f.Title
+		if f.Verdict != "" {
+			title += " (" + f.Verdict + ")"
+		}
+		findings.Rows = append(findings.Rows, []string{string(f.Severity), f.Insight, orNone(f.Resource), title})
+	}
+	r.Sections = append(r.Sections, findings)
+	return r
+}
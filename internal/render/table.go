@@ -0,0 +1,29 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+func renderTable(w io.Writer, r report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, r.Title)
+	for _, s := range r.Sections {
+		fmt.Fprintf(tw, "\n%s\n", strings.ToUpper(s.Title))
+		if len(s.Rows) == 0 {
+			fmt.Fprintln(tw, s.Empty)
+			continue
+		}
+		fmt.Fprintln(tw, strings.Join(s.Header, "\t"))
+		for _, row := range s.Rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		// Each section is aligned on its own
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
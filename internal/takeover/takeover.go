@@ -0,0 +1,233 @@
+// Package takeover scans the records of a Route53 hosted zone for dangling
+// records, records that point to resources which no longer exist and whose
+// names may be claimed by anyone to serve content on the zone's domains.
+package takeover
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/unfor19/columbus-app/internal/aws/accounts"
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+	croute53 "github.com/unfor19/columbus-app/internal/aws/service/route53"
+	cs3 "github.com/unfor19/columbus-app/internal/aws/service/s3"
+	"github.com/unfor19/columbus-app/internal/explorer"
+	"github.com/unfor19/columbus-app/internal/insights"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
+)
+
+var ErrNoHostedZone = errors.New("no Route53 hosted zone found")
+
+// Services of a Finding
+const (
+	ServiceCloudFront       = "cloudfront"
+	ServiceS3               = "s3"
+	ServiceS3Website        = "s3-website"
+	ServiceElasticBeanstalk = "elasticbeanstalk"
+	ServiceElb              = "elb"
+)
+
+// Finding is a record that points to a resource which does not exist
+type Finding struct {
+	Record croute53.Route53Record
+	// Target is the alias target or CNAME value of the record
+	Target  string
+	Service string
+	// Resource is the missing distribution, bucket or endpoint
+	Resource string
+	Risk     insights.Severity
+	Message  string
+}
+
+// Result is the scan of the hosted zones of a domain
+type Result struct {
+	Zones    []string
+	Records  int
+	Findings []Finding
+}
+
+// Checker tells whether the targets of a record exist
+type Checker struct {
+	// Distributions maps the domain names of the distributions to their ids,
+	// CloudFront targets are not checked when it is nil
+	Distributions map[string]string
+	BucketExists  func(ctx context.Context, bucketName string) bool
+	Resolve       func(ctx context.Context, domainName string) (cdns.Resolution, error)
+}
+
+// targets returns the names the record points to
+func targets(record croute53.Route53Record) []string {
+	var names []string
+	if record.AliasTarget != nil {
+		names = append(names, record.AliasTarget.DNSName)
+	}
+	if record.Type == "CNAME" {
+		names = append(names, record.Values...)
+	}
+	return names
+}
+
+// Check returns a Finding for each target of the record that does not exist
+func (c Checker) Check(ctx context.Context, record croute53.Route53Record) []Finding {
+	var findings []Finding
+	for _, target := range targets(record) {
+		name := strings.ToLower(strings.TrimSuffix(target, "."))
+		f := Finding{Record: record, Target: name, Resource: name}
+		switch {
+		case ccloudfront.IsDistributionDomain(name):
+			if c.Distributions == nil {
+				continue
+			}
+			if _, ok := c.Distributions[name]; ok {
+				continue
+			}
+			f.Service = ServiceCloudFront
+			f.Risk = insights.SeverityHigh
+			f.Message = "The distribution " + name + " is not in the explored accounts, once deleted the record's name can be added as an alias to another distribution"
+		case strings.HasPrefix(name, "s3-website") && record.AliasTarget != nil:
+			// Aliases to website endpoints serve the bucket named as the record
+			f.Service = ServiceS3Website
+			f.Resource = strings.TrimSuffix(record.Name, ".")
+		case strings.HasSuffix(name, ".elasticbeanstalk.com"):
+			f.Service = ServiceElasticBeanstalk
+		case strings.HasSuffix(name, ".elb.amazonaws.com"):
+			f.Service = ServiceElb
+		default:
+			endpoint, ok := cs3.ParseBucketEndpoint(name)
+			if !ok {
+				continue
+			}
+			f.Service = ServiceS3
+			if endpoint.Website {
+				f.Service = ServiceS3Website
+			}
+			f.Resource = endpoint.Bucket
+		}
+
+		switch f.Service {
+		case ServiceS3, ServiceS3Website:
+			if c.BucketExists(ctx, f.Resource) {
+				continue
+			}
+			f.Risk = insights.SeverityHigh
+			f.Message = "The bucket " + f.Resource + " does not exist or is not accessible, anyone can create it and serve content on " + record.Name
+		case ServiceElasticBeanstalk, ServiceElb:
+			resolution, err := c.Resolve(ctx, name)
+			if err != nil {
+				log.Println("Failed to resolve", name, err)
+				continue
+			}
+			if resolution.Rcode != "NXDOMAIN" {
+				continue
+			}
+			if f.Service == ServiceElasticBeanstalk {
+				f.Risk = insights.SeverityHigh
+				f.Message = "The Elastic Beanstalk environment " + name + " does not exist, anyone can create an environment with its CNAME prefix"
+			} else {
+				f.Risk = insights.SeverityMedium
+				f.Message = "The load balancer " + name + " does not exist, its name cannot be claimed again but the record is broken"
+			}
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// newChecker checks the targets with the accounts of the explorer's
+// configuration and its DNS servers
+func newChecker(ctx context.Context, config explorer.Config, acc *accounts.Accounts) Checker {
+	c := Checker{
+		BucketExists: func(ctx context.Context, bucketName string) bool {
+			// S3 returns the region of existing buckets only, even to
+			// another account
+			region, err := cs3.GetS3BucketRegion(ctx, acc.Config(accounts.ServiceS3), bucketName)
+			if err == nil {
+				return true
+			}
+			log.Println(err)
+			for _, cfg := range acc.Candidates(accounts.ServiceS3) {
+				missing, err := cs3.GetS3BucketMissing(ctx, cs3.ConfigForRegion(cfg, region), bucketName)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				return !missing
+			}
+			// Only a bucket that S3 reports as missing can be claimed
+			return true
+		},
+		Resolve: func(ctx context.Context, domainName string) (cdns.Resolution, error) {
+			var resolution cdns.Resolution
+			var err error
+			for _, dnsServer := range config.DnsServers {
+				resolution, err = cdns.Resolve(ctx, domainName, dnsServer)
+				if err == nil && resolution.Rcode != "SERVFAIL" {
+					break
+				}
+			}
+			return resolution, err
+		},
+	}
+	for _, cfg := range acc.Candidates(accounts.ServiceCloudFront) {
+		distributions, err := ccloudfront.ListCloudfrontDistributions(ctx, cfg)
+		if err != nil {
+			log.Println("Failed to list CloudFront distributions:", err)
+			continue
+		}
+		if c.Distributions == nil {
+			c.Distributions = map[string]string{}
+		}
+		for _, d := range distributions {
+			c.Distributions[strings.ToLower(aws.ToString(d.DomainName))] = aws.ToString(d.Id)
+		}
+	}
+	return c
+}
+
+// Scan checks every record of the public and private hosted zones with the
+// most specific name that serves domainName, the highest risks first.
+func Scan(ctx context.Context, config explorer.Config, domainName string) (Result, error) {
+	var result Result
+	cfg, err := config.LoadAwsConfig(ctx)
+	if err != nil {
+		return result, err
+	}
+	acc := accounts.New(cfg, config.Roles)
+
+	var records []croute53.Route53Record
+	for _, cfg := range acc.Candidates(accounts.ServiceRoute53) {
+		zones, err := croute53.GetHostedZones(ctx, cfg, domainName)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for _, zone := range zones {
+			zoneRecords, err := croute53.ListZoneRecords(ctx, cfg, zone)
+			if err != nil {
+				return result, err
+			}
+			result.Zones = append(result.Zones, aws.ToString(zone.Name))
+			records = append(records, zoneRecords...)
+		}
+		if len(zones) > 0 {
+			break
+		}
+	}
+	if len(result.Zones) == 0 {
+		return result, ErrNoHostedZone
+	}
+	result.Records = len(records)
+
+	checker := newChecker(ctx, config, acc)
+	for _, record := range records {
+		result.Findings = append(result.Findings, checker.Check(ctx, record)...)
+	}
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		return result.Findings[i].Risk.Rank() > result.Findings[j].Risk.Rank()
+	})
+	return result, nil
+}
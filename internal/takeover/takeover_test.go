@@ -0,0 +1,63 @@
+package takeover
+
+import (
+	"context"
+	"testing"
+
+	croute53 "github.com/unfor19/columbus-app/internal/aws/service/route53"
+	"github.com/unfor19/columbus-app/internal/insights"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
+)
+
+func TestCheck(t *testing.T) {
+	checker := Checker{
+		Distributions: map[string]string{"d111.cloudfront.net": "E111"},
+		BucketExists: func(ctx context.Context, bucketName string) bool {
+			return bucketName == "assets.example.com"
+		},
+		Resolve: func(ctx context.Context, domainName string) (cdns.Resolution, error) {
+			if domainName == "live.eu-west-1.elasticbeanstalk.com" {
+				return cdns.Resolution{Rcode: "NOERROR"}, nil
+			}
+			return cdns.Resolution{Rcode: "NXDOMAIN"}, nil
+		},
+	}
+	tests := []struct {
+		name    string
+		record  croute53.Route53Record
+		service string
+		risk    insights.Severity
+	}{
+		{"existing distribution", croute53.Route53Record{Name: "www.example.com.", Type: "A", AliasTarget: &croute53.AliasTarget{DNSName: "d111.cloudfront.net."}}, "", ""},
+		{"deleted distribution", croute53.Route53Record{Name: "old.example.com.", Type: "CNAME", Values: []string{"d222.cloudfront.net"}}, ServiceCloudFront, insights.SeverityHigh},
+		{"existing website alias", croute53.Route53Record{Name: "assets.example.com.", Type: "A", AliasTarget: &croute53.AliasTarget{DNSName: "s3-website-eu-west-1.amazonaws.com."}}, "", ""},
+		{"deleted website alias", croute53.Route53Record{Name: "static.example.com.", Type: "A", AliasTarget: &croute53.AliasTarget{DNSName: "s3-website-eu-west-1.amazonaws.com."}}, ServiceS3Website, insights.SeverityHigh},
+		{"deleted bucket", croute53.Route53Record{Name: "files.example.com.", Type: "CNAME", Values: []string{"files-bucket.s3.amazonaws.com"}}, ServiceS3, insights.SeverityHigh},
+		{"existing environment", croute53.Route53Record{Name: "app.example.com.", Type: "CNAME", Values: []string{"live.eu-west-1.elasticbeanstalk.com"}}, "", ""},
+		{"deleted environment", croute53.Route53Record{Name: "beta.example.com.", Type: "CNAME", Values: []string{"beta.eu-west-1.elasticbeanstalk.com"}}, ServiceElasticBeanstalk, insights.SeverityHigh},
+		{"deleted load balancer", croute53.Route53Record{Name: "api.example.com.", Type: "A", AliasTarget: &croute53.AliasTarget{DNSName: "dualstack.api-123.eu-west-1.elb.amazonaws.com."}}, ServiceElb, insights.SeverityMedium},
+		{"other target", croute53.Route53Record{Name: "mail.example.com.", Type: "CNAME", Values: []string{"mail.example.net"}}, "", ""},
+		{"address", croute53.Route53Record{Name: "ns.example.com.", Type: "A", Values: []string{"192.0.2.1"}}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := checker.Check(context.Background(), tt.record)
+			if tt.service == "" {
+				if len(findings) != 0 {
+					t.Fatal("Expected no findings, got", findings)
+				}
+				return
+			}
+			if len(findings) != 1 || findings[0].Service != tt.service || findings[0].Risk != tt.risk {
+				t.Fatal("Expected a", tt.risk, tt.service, "finding, got", findings)
+			}
+		})
+	}
+
+	// Without the distributions, CloudFront targets cannot be checked
+	checker.Distributions = nil
+	record := croute53.Route53Record{Name: "old.example.com.", Type: "CNAME", Values: []string{"d222.cloudfront.net"}}
+	if findings := checker.Check(context.Background(), record); len(findings) != 0 {
+		t.Fatal("Expected no findings, got", findings)
+	}
+}
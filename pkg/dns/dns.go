@@ -1,51 +1,58 @@
 package dns
 
 import (
-	"fmt"
+	"context"
 	"log"
 	"net"
 	"net/url"
-	"regexp"
 	"strings"
-
-	"github.com/miekg/dns"
 )
 
+// ParseRequestUrl parses a URL, a bare hostname without a scheme is parsed
+// as an https URL
+func ParseRequestUrl(requestUrl string) (*url.URL, error) {
+	if !strings.Contains(requestUrl, "://") {
+		requestUrl = "https://" + requestUrl
+	}
+	return url.Parse(requestUrl)
+}
+
+// GetDomainName returns the hostname of the URL, without its port, path and
+// query, or an empty string when it is invalid
 func GetDomainName(requestUrl string) string {
-	httpRegex := regexp.MustCompile(`^http.*:\/\/`)
-	domainName := httpRegex.ReplaceAllString(requestUrl, "")
-	return domainName
+	u, err := ParseRequestUrl(requestUrl)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
 }
 
+// GetRegisteredDomainName returns the registered domain of the URL's host,
+// according to the Public Suffix List, or an empty string when it has none
 func GetRegisteredDomainName(requestUrl string) string {
-	u, err := url.Parse(requestUrl)
+	host := GetDomainName(requestUrl)
+	if host == "" {
+		return ""
+	}
+	domain, err := RegisteredDomain(host)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
+		return ""
 	}
-	parts := strings.Split(u.Hostname(), ".")
-	domain := parts[len(parts)-2] + "." + parts[len(parts)-1]
 	return domain
 }
 
+// GetTargetIPAddress returns the first IPv4 address of domainName, or its
+// first IPv6 address, nil when it does not resolve
 func GetTargetIPAddress(domainName string, dnsServer string) net.IP {
-	m1 := new(dns.Msg)
-	m1.Id = dns.Id()
-	m1.RecursionDesired = true
-	m1.Question = make([]dns.Question, 1)
-	m1.Question[0] = dns.Question{
-		Name:   fmt.Sprintf(`%s.`, domainName),
-		Qtype:  dns.TypeA,
-		Qclass: dns.ClassINET,
+	resolution, err := Resolve(context.Background(), domainName, dnsServer)
+	if err != nil {
+		log.Println(err)
+		return nil
 	}
-	c := new(dns.Client)
-	in, _, _ := c.Exchange(m1, dnsServer)
-	if len(in.Answer) > 0 {
-		if t, ok := in.Answer[len(in.Answer)-1].(*dns.A); ok {
-			return t.A
-		} else {
-			log.Println(in)
-			return nil
-		}
+	if ips := resolution.IPs(); len(ips) > 0 {
+		return ips[0]
 	}
 	return nil
 }
@@ -0,0 +1,160 @@
+package dns
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/idna"
+)
+
+//go:generate curl -sSfLo public_suffix_list.dat https://publicsuffix.org/list/public_suffix_list.dat
+
+// The bundled Public Suffix List, update it with go generate
+//
+//go:embed public_suffix_list.dat
+var bundledPublicSuffixList string
+
+var ErrNoRegisteredDomain = errors.New("no registered domain")
+
+// PublicSuffixList holds the ICANN section of the Public Suffix List. The
+// private section is ignored, domains such as s3.amazonaws.com are suffixes
+// there but are still served by the zone of their registered domain.
+type PublicSuffixList struct {
+	rules      map[string]bool
+	wildcards  map[string]bool
+	exceptions map[string]bool
+}
+
+// ParsePublicSuffixList parses a list in the publicsuffix.org format
+func ParsePublicSuffixList(r io.Reader) (*PublicSuffixList, error) {
+	l := &PublicSuffixList{rules: map[string]bool{}, wildcards: map[string]bool{}, exceptions: map[string]bool{}}
+	scanner := bufio.NewScanner(r)
+	icann := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "// ===BEGIN ICANN DOMAINS==="):
+			icann = true
+			continue
+		case strings.HasPrefix(line, "// ===END ICANN DOMAINS==="):
+			icann = false
+			continue
+		case !icann || line == "" || strings.HasPrefix(line, "//"):
+			continue
+		}
+		// Rules end at the first whitespace
+		rule := strings.Fields(line)[0]
+		rules := l.rules
+		if strings.HasPrefix(rule, "!") {
+			rule, rules = rule[1:], l.exceptions
+		} else if strings.HasPrefix(rule, "*.") {
+			rule, rules = rule[2:], l.wildcards
+		}
+		ascii, err := idna.Lookup.ToASCII(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public suffix rule %q: %w", line, err)
+		}
+		rules[ascii] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(l.rules) == 0 {
+		return nil, errors.New("invalid public suffix list, no ICANN rules")
+	}
+	return l, nil
+}
+
+// PublicSuffix returns the public suffix of host, the last label when no
+// rule matches
+func (l *PublicSuffixList) PublicSuffix(host string) string {
+	labels := strings.Split(host, ".")
+	suffix := labels[len(labels)-1]
+	for i := len(labels) - 1; i >= 0; i-- {
+		name := strings.Join(labels[i:], ".")
+		switch {
+		case l.exceptions[name]:
+			return strings.Join(labels[i+1:], ".")
+		case l.rules[name]:
+			suffix = name
+		case i+1 < len(labels) && l.wildcards[strings.Join(labels[i+1:], ".")]:
+			suffix = name
+		}
+	}
+	return suffix
+}
+
+// RegisteredDomain returns the public suffix of name with one more label,
+// such as example.co.uk for app.example.co.uk
+func (l *PublicSuffixList) RegisteredDomain(name string) (string, error) {
+	host, err := idna.Lookup.ToASCII(strings.TrimSuffix(name, "."))
+	if err != nil {
+		return "", fmt.Errorf("%w for %q: %v", ErrNoRegisteredDomain, name, err)
+	}
+	if host == "" {
+		return "", fmt.Errorf("%w for an empty host", ErrNoRegisteredDomain)
+	}
+	suffix := l.PublicSuffix(host)
+	if len(host) <= len(suffix) {
+		return "", fmt.Errorf("%w for %q, it is a public suffix", ErrNoRegisteredDomain, host)
+	}
+	label := host[:len(host)-len(suffix)-1]
+	if i := strings.LastIndexByte(label, '.'); i >= 0 {
+		label = label[i+1:]
+	}
+	return label + "." + suffix, nil
+}
+
+var (
+	publicSuffixListMu sync.RWMutex
+	publicSuffixList   *PublicSuffixList
+)
+
+// DefaultPublicSuffixList returns the list set by LoadPublicSuffixList, or the
+// bundled list
+func DefaultPublicSuffixList() *PublicSuffixList {
+	publicSuffixListMu.RLock()
+	l := publicSuffixList
+	publicSuffixListMu.RUnlock()
+	if l != nil {
+		return l
+	}
+	publicSuffixListMu.Lock()
+	defer publicSuffixListMu.Unlock()
+	if publicSuffixList == nil {
+		l, err := ParsePublicSuffixList(strings.NewReader(bundledPublicSuffixList))
+		if err != nil {
+			panic(err)
+		}
+		publicSuffixList = l
+	}
+	return publicSuffixList
+}
+
+// LoadPublicSuffixList replaces the bundled list with a newer one
+func LoadPublicSuffixList(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	l, err := ParsePublicSuffixList(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filePath, err)
+	}
+	publicSuffixListMu.Lock()
+	defer publicSuffixListMu.Unlock()
+	publicSuffixList = l
+	return nil
+}
+
+// RegisteredDomain returns the registered domain of host with the default list
+func RegisteredDomain(host string) (string, error) {
+	return DefaultPublicSuffixList().RegisteredDomain(host)
+}
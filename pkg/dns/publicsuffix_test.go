@@ -0,0 +1,83 @@
+package dns
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegisteredDomain(t *testing.T) {
+	for host, expected := range map[string]string{
+		"app.example.co.uk": "example.co.uk",
+		"example.co.uk":     "example.co.uk",
+		"dev.sokker.info.":  "sokker.info",
+		"WWW.Example.COM":   "example.com",
+		"lwpcc2dff2.execute-api.eu-west-1.amazonaws.com": "amazonaws.com",
+		"a.b.city.kawasaki.jp":                           "city.kawasaki.jp",
+		"a.city.kawasaki.jp":                             "city.kawasaki.jp",
+		"a.b.kobe.jp":                                    "a.b.kobe.jp",
+		"www.ck":                                         "www.ck",
+		"shop.example.xn--p1ai":                          "example.xn--p1ai",
+		"shop.пример.рф":                                 "xn--e1afmkfd.xn--p1ai",
+		"host.unknown-tld":                               "host.unknown-tld",
+	} {
+		if domain, err := RegisteredDomain(host); err != nil || domain != expected {
+			t.Errorf("%s: expected %s, got %s %v", host, expected, domain, err)
+		}
+	}
+	for _, host := range []string{"localhost", "co.uk", "", "b.kobe.jp"} {
+		if domain, err := RegisteredDomain(host); err == nil {
+			t.Errorf("%s: expected an error, got %s", host, domain)
+		}
+	}
+}
+
+func TestGetDomainName(t *testing.T) {
+	for requestUrl, expected := range map[string]string{
+		"https://Dev.Example.com/docs/?page=1": "dev.example.com",
+		"https://dev.example.com:8443/":        "dev.example.com",
+		"http://dev.example.com":               "dev.example.com",
+		"dev.example.com/docs/":                "dev.example.com",
+		"dev.example.com":                      "dev.example.com",
+		"https://[::1]:8443/":                  "::1",
+	} {
+		if domainName := GetDomainName(requestUrl); domainName != expected {
+			t.Errorf("%s: expected %q, got %q", requestUrl, expected, domainName)
+		}
+	}
+}
+
+func TestGetRegisteredDomainName(t *testing.T) {
+	for requestUrl, expected := range map[string]string{
+		"https://app.example.co.uk/index.html": "example.co.uk",
+		"https://dev.sokker.info:8443":         "sokker.info",
+		"dev.sokker.info/path":                 "sokker.info",
+		"http://localhost:8080":                "",
+	} {
+		if domain := GetRegisteredDomainName(requestUrl); domain != expected {
+			t.Errorf("%s: expected %q, got %q", requestUrl, expected, domain)
+		}
+	}
+}
+
+func TestLoadPublicSuffixList(t *testing.T) {
+	defer func() { publicSuffixList = nil }()
+	path := filepath.Join(t.TempDir(), "public_suffix_list.dat")
+	list := "// ===BEGIN ICANN DOMAINS===\nuk\nco.uk\nexample.uk\n// ===END ICANN DOMAINS===\n// ===BEGIN PRIVATE DOMAINS===\nprivate.uk\n"
+	if err := ioutil.WriteFile(path, []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadPublicSuffixList(path); err != nil {
+		t.Fatal(err)
+	}
+	if domain, _ := RegisteredDomain("a.b.example.uk"); domain != "b.example.uk" {
+		t.Errorf("expected the loaded list, got %s", domain)
+	}
+	if domain, _ := RegisteredDomain("a.b.private.uk"); domain != "private.uk" {
+		t.Errorf("expected the private section to be ignored, got %s", domain)
+	}
+	if _, err := ParsePublicSuffixList(strings.NewReader("<html></html>")); err == nil {
+		t.Error("expected an error for a list without ICANN rules")
+	}
+}
@@ -0,0 +1,161 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Resolvers may stop at a CNAME, the chain is followed up to maxCnameHops
+const maxCnameHops = 8
+
+var ErrCnameLoop = errors.New("CNAME chain is too long or loops")
+
+// Record is a resource record of a resolution
+type Record struct {
+	Name  string
+	Type  string
+	Ttl   uint32
+	Value string
+}
+
+// String formats the record as in a zone file
+func (r Record) String() string {
+	return fmt.Sprintf("%s %d IN %s %s", r.Name, r.Ttl, r.Type, r.Value)
+}
+
+// Resolution is how a hostname resolves, through its CNAME chain, to its
+// A and AAAA records
+type Resolution struct {
+	Name string
+	// Server is the resolver that answered
+	Server string
+	// Rcode of the answer, such as NOERROR or NXDOMAIN
+	Rcode      string
+	CnameChain []Record
+	Addresses  []Record
+}
+
+// Canonical is the name the addresses belong to, at the end of the CNAME chain
+func (r Resolution) Canonical() string {
+	if len(r.CnameChain) == 0 {
+		return r.Name
+	}
+	return r.CnameChain[len(r.CnameChain)-1].Value
+}
+
+// IPs returns the addresses, IPv4 first
+func (r Resolution) IPs() []net.IP {
+	var ipv4, ipv6 []net.IP
+	for _, a := range r.Addresses {
+		ip := net.ParseIP(a.Value)
+		if a.Type == "A" {
+			ipv4 = append(ipv4, ip)
+		} else {
+			ipv6 = append(ipv6, ip)
+		}
+	}
+	return append(ipv4, ipv6...)
+}
+
+func exchange(ctx context.Context, name string, qtype uint16, dnsServer string) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.RecursionDesired = true
+	c := new(dns.Client)
+	in, _, err := c.ExchangeContext(ctx, m, dnsServer)
+	if err == nil && in.Truncated {
+		c.Net = "tcp"
+		in, _, err = c.ExchangeContext(ctx, m, dnsServer)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s %s at %s: %w", dns.TypeToString[qtype], name, dnsServer, err)
+	}
+	return in, nil
+}
+
+// follow walks the CNAME chain of the answer from name, and returns the
+// name at its end with its records
+func follow(answer []dns.RR, name string, chain []Record) (string, []Record, []Record, error) {
+	var records []Record
+	for followed := true; followed; {
+		followed = false
+		for _, rr := range answer {
+			header := rr.Header()
+			if !strings.EqualFold(header.Name, name) {
+				continue
+			}
+			if cname, ok := rr.(*dns.CNAME); ok {
+				if len(chain) == maxCnameHops {
+					return name, chain, records, ErrCnameLoop
+				}
+				chain = append(chain, Record{Name: header.Name, Type: "CNAME", Ttl: header.Ttl, Value: cname.Target})
+				name = cname.Target
+				followed = true
+				break
+			}
+		}
+	}
+	for _, rr := range answer {
+		header := rr.Header()
+		if !strings.EqualFold(header.Name, name) {
+			continue
+		}
+		switch v := rr.(type) {
+		case *dns.A:
+			records = append(records, Record{Name: header.Name, Type: "A", Ttl: header.Ttl, Value: v.A.String()})
+		case *dns.AAAA:
+			records = append(records, Record{Name: header.Name, Type: "AAAA", Ttl: header.Ttl, Value: v.AAAA.String()})
+		}
+	}
+	return name, chain, records, nil
+}
+
+// query asks for the qtype records of name, following the CNAME chain when
+// the resolver stops at a CNAME. It returns the chain, the records and the
+// RCODE.
+func query(ctx context.Context, name string, qtype uint16, dnsServer string) ([]Record, []Record, int, error) {
+	var chain []Record
+	current := dns.Fqdn(name)
+	for {
+		in, err := exchange(ctx, current, qtype, dnsServer)
+		if err != nil {
+			return chain, nil, 0, err
+		}
+		if in.Rcode != dns.RcodeSuccess {
+			return chain, nil, in.Rcode, nil
+		}
+		target, followed, records, err := follow(in.Answer, current, chain)
+		chain = followed
+		if err != nil || len(records) > 0 || strings.EqualFold(target, current) {
+			return chain, records, in.Rcode, err
+		}
+		current = target
+	}
+}
+
+// Resolve resolves the A and AAAA records of domainName at dnsServer. A
+// name that does not exist is not an error, its Rcode is NXDOMAIN.
+func Resolve(ctx context.Context, domainName string, dnsServer string) (Resolution, error) {
+	r := Resolution{Name: dns.Fqdn(domainName), Server: dnsServer}
+	chain, ipv4, rcode, err := query(ctx, domainName, dns.TypeA, dnsServer)
+	r.CnameChain = chain
+	r.Addresses = ipv4
+	r.Rcode = dns.RcodeToString[rcode]
+	if err != nil || rcode != dns.RcodeSuccess {
+		return r, err
+	}
+	// The chain is the same, the AAAA records are queried at its end
+	_, ipv6, rcode, err := query(ctx, r.Canonical(), dns.TypeAAAA, dnsServer)
+	if err != nil {
+		return r, err
+	}
+	if rcode == dns.RcodeSuccess {
+		r.Addresses = append(r.Addresses, ipv6...)
+	}
+	return r, nil
+}
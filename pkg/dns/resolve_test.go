@@ -0,0 +1,116 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// testZone answers like a recursive resolver, www is answered with the
+// whole chain and stop.example.com. stops at a CNAME
+var testZone = map[uint16][]string{
+	dns.TypeA: {
+		"www.example.com. 300 IN CNAME edge.example.com.",
+		"edge.example.com. 60 IN CNAME d111111abcdef8.cloudfront.net.",
+		"d111111abcdef8.cloudfront.net. 20 IN A 13.225.250.115",
+		"d111111abcdef8.cloudfront.net. 20 IN A 13.225.250.7",
+		"stop.example.com. 300 IN CNAME d111111abcdef8.cloudfront.net.",
+		"loop.example.com. 300 IN CNAME loop.example.com.",
+	},
+	dns.TypeAAAA: {
+		"www.example.com. 300 IN CNAME edge.example.com.",
+		"edge.example.com. 60 IN CNAME d111111abcdef8.cloudfront.net.",
+		"d111111abcdef8.cloudfront.net. 20 IN AAAA 2600:9000:2000::1",
+		"stop.example.com. 300 IN CNAME d111111abcdef8.cloudfront.net.",
+	},
+}
+
+func startTestServer(t *testing.T) string {
+	t.Helper()
+	// The names that www.example.com. is answered with
+	chain := map[string]bool{"www.example.com.": true, "edge.example.com.": true, "d111111abcdef8.cloudfront.net.": true}
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		m.Rcode = dns.RcodeNameError
+		for _, record := range testZone[dns.TypeA] {
+			if rr, _ := dns.NewRR(record); rr.Header().Name == q.Name {
+				m.Rcode = dns.RcodeSuccess
+			}
+		}
+		for _, record := range testZone[q.Qtype] {
+			rr, _ := dns.NewRR(record)
+			name := rr.Header().Name
+			if name == q.Name || (q.Name == "www.example.com." && chain[name]) {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		w.WriteMsg(m)
+	})
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: handler}
+	started := make(chan struct{})
+	server.NotifyStartedFunc = func() { close(started) }
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestResolve(t *testing.T) {
+	server := startTestServer(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"www.example.com", "stop.example.com"} {
+		r, err := Resolve(ctx, name, server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Rcode != "NOERROR" || r.Server != server || r.Canonical() != "d111111abcdef8.cloudfront.net." {
+			t.Errorf("%s: unexpected resolution %+v", name, r)
+		}
+		var addresses []string
+		for _, a := range r.Addresses {
+			addresses = append(addresses, a.String())
+		}
+		expected := []string{
+			"d111111abcdef8.cloudfront.net. 20 IN A 13.225.250.115",
+			"d111111abcdef8.cloudfront.net. 20 IN A 13.225.250.7",
+			"d111111abcdef8.cloudfront.net. 20 IN AAAA 2600:9000:2000::1",
+		}
+		if !reflect.DeepEqual(addresses, expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, addresses)
+		}
+	}
+
+	r, _ := Resolve(ctx, "www.example.com", server)
+	if len(r.CnameChain) != 2 || r.CnameChain[0].Value != "edge.example.com." || r.CnameChain[0].Ttl != 300 {
+		t.Errorf("unexpected CNAME chain %v", r.CnameChain)
+	}
+	if ips := r.IPs(); len(ips) != 3 || !ips[0].Equal(net.ParseIP("13.225.250.115")) {
+		t.Errorf("unexpected IPs %v", ips)
+	}
+
+	r, err := Resolve(ctx, "missing.example.com", server)
+	if err != nil || r.Rcode != "NXDOMAIN" || len(r.Addresses) != 0 {
+		t.Errorf("expected NXDOMAIN, got %+v %v", r, err)
+	}
+
+	if _, err := Resolve(ctx, "loop.example.com", server); err != ErrCnameLoop {
+		t.Errorf("expected a CNAME loop error, got %v", err)
+	}
+
+	if ip := GetTargetIPAddress("www.example.com", server); !ip.Equal(net.ParseIP("13.225.250.115")) {
+		t.Errorf("unexpected target IP %v", ip)
+	}
+	if ip := GetTargetIPAddress("missing.example.com", server); ip != nil {
+		t.Errorf("expected no target IP, got %v", ip)
+	}
+}
@@ -0,0 +1,279 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// RootServers are the addresses of a few root name servers, a trace starts
+// at the first one that answers
+var RootServers = []string{
+	"198.41.0.4:53",   // a.root-servers.net
+	"199.9.14.201:53", // b.root-servers.net
+	"192.33.4.12:53",  // c.root-servers.net
+	"199.7.83.42:53",  // l.root-servers.net
+}
+
+// The port name servers listen on, tests run them on another port
+var nameServerPort = "53"
+
+// A referral chain longer than this is a delegation loop
+const maxDelegations = 16
+
+var ErrDelegationLoop = errors.New("too many delegations")
+
+// DelegationStep is a referral from a server to the name servers of Zone
+type DelegationStep struct {
+	Zone        string
+	Server      string
+	NameServers []string
+}
+
+// NameServerCheck is the answer of a delegated name server for its zone
+type NameServerCheck struct {
+	NameServer string
+	Address    string
+	// Authoritative is false for a lame delegation
+	Authoritative bool
+	Rcode         string `json:",omitempty"`
+	// NameServers are the NS records of the zone as served by the server
+	NameServers []string
+	Error       string `json:",omitempty"`
+}
+
+// Trace is the delegation chain of a name from the root to the zone it is
+// in, and the answers of that zone's name servers
+type Trace struct {
+	Name string
+	// Zone is the most specific zone that is delegated, and NameServers
+	// its delegation in the parent zone
+	Zone        string
+	NameServers []string
+	Steps       []DelegationStep
+	Servers     []NameServerCheck
+}
+
+func nameServers(rrs []dns.RR, owner string) []string {
+	var names []string
+	for _, rr := range rrs {
+		if ns, ok := rr.(*dns.NS); ok && (owner == "" || strings.EqualFold(ns.Hdr.Name, owner)) {
+			names = append(names, strings.ToLower(ns.Ns))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func iterativeExchange(ctx context.Context, name string, qtype uint16, server string) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.RecursionDesired = false
+	c := new(dns.Client)
+	in, _, err := c.ExchangeContext(ctx, m, server)
+	if err == nil && in.Truncated {
+		c.Net = "tcp"
+		in, _, err = c.ExchangeContext(ctx, m, server)
+	}
+	return in, err
+}
+
+// referral returns the zone and name servers that the answer delegates to,
+// when it is a referral to a child of zone
+func referral(in *dns.Msg, zone string) (string, []string) {
+	if in.Authoritative || in.Rcode != dns.RcodeSuccess || len(in.Answer) > 0 {
+		return "", nil
+	}
+	for _, rr := range in.Ns {
+		child := rr.Header().Name
+		if _, ok := rr.(*dns.NS); ok && dns.IsSubDomain(zone, child) && !strings.EqualFold(child, zone) {
+			return strings.ToLower(child), nameServers(in.Ns, child)
+		}
+	}
+	return "", nil
+}
+
+// addresses returns the glue addresses of nameServer, or resolves them
+func addresses(ctx context.Context, nameServer string, glue []dns.RR, resolver string) []string {
+	var addrs []string
+	for _, rr := range glue {
+		if !strings.EqualFold(rr.Header().Name, nameServer) {
+			continue
+		}
+		switch v := rr.(type) {
+		case *dns.A:
+			addrs = append(addrs, net.JoinHostPort(v.A.String(), nameServerPort))
+		case *dns.AAAA:
+			addrs = append(addrs, net.JoinHostPort(v.AAAA.String(), nameServerPort))
+		}
+	}
+	if len(addrs) > 0 || resolver == "" {
+		return addrs
+	}
+	resolution, err := Resolve(ctx, nameServer, resolver)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range resolution.IPs() {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), nameServerPort))
+	}
+	return addrs
+}
+
+// TraceDelegation follows the referrals for name from startServer, or from
+// the root servers when it is empty, to the name servers of its zone, and
+// asks each of them for the zone's NS records. The resolver resolves the
+// name servers that have no glue records.
+func TraceDelegation(ctx context.Context, name string, startServer string, resolver string) (Trace, error) {
+	t := Trace{Name: dns.Fqdn(strings.ToLower(name)), Zone: "."}
+	servers := RootServers
+	if startServer != "" {
+		servers = []string{startServer}
+	}
+	var glue []dns.RR
+	for {
+		var in *dns.Msg
+		var server string
+		var err error
+		for _, server = range servers {
+			if in, err = iterativeExchange(ctx, t.Name, dns.TypeNS, server); err == nil {
+				break
+			}
+		}
+		if in == nil {
+			if err == nil {
+				err = fmt.Errorf("no name server address for %s", t.Zone)
+			}
+			return t, fmt.Errorf("failed to trace %s at %s: %w", t.Name, t.Zone, err)
+		}
+		zone, delegated := referral(in, t.Zone)
+		if zone == "" {
+			break
+		}
+		if len(t.Steps) == maxDelegations {
+			return t, ErrDelegationLoop
+		}
+		t.Steps = append(t.Steps, DelegationStep{Zone: zone, Server: server, NameServers: delegated})
+		t.Zone, t.NameServers, glue = zone, delegated, in.Extra
+		servers = nil
+		for _, ns := range delegated {
+			servers = append(servers, addresses(ctx, ns, glue, resolver)...)
+		}
+	}
+
+	for _, ns := range t.NameServers {
+		addrs := addresses(ctx, ns, glue, resolver)
+		if len(addrs) == 0 {
+			t.Servers = append(t.Servers, NameServerCheck{NameServer: ns, Error: "no address"})
+			continue
+		}
+		for _, addr := range addrs {
+			check := NameServerCheck{NameServer: ns, Address: addr}
+			in, err := iterativeExchange(ctx, t.Zone, dns.TypeNS, addr)
+			if err != nil {
+				check.Error = err.Error()
+			} else {
+				check.Rcode = dns.RcodeToString[in.Rcode]
+				check.Authoritative = in.Authoritative && in.Rcode == dns.RcodeSuccess
+				check.NameServers = nameServers(in.Answer, t.Zone)
+			}
+			t.Servers = append(t.Servers, check)
+		}
+	}
+	return t, nil
+}
+
+// CompareNameServers returns the name servers of expected that are not in
+// actual, and the ones of actual that are not expected
+func CompareNameServers(expected []string, actual []string) (missing []string, extra []string) {
+	set := func(names []string) map[string]bool {
+		m := map[string]bool{}
+		for _, n := range names {
+			m[dns.Fqdn(strings.ToLower(n))] = true
+		}
+		return m
+	}
+	e, a := set(expected), set(actual)
+	for n := range e {
+		if !a[n] {
+			missing = append(missing, n)
+		}
+	}
+	for n := range a {
+		if !e[n] {
+			extra = append(extra, n)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
+
+const (
+	// A name server of the delegation does not answer authoritatively
+	ProblemLame = "lame"
+	// The zone's own NS records differ from its delegation
+	ProblemInconsistent = "inconsistent"
+	// The zone is not delegated, a less specific zone serves the name
+	ProblemNotDelegated = "not-delegated"
+	// The delegation differs from the expected name servers
+	ProblemMismatch = "mismatch"
+)
+
+type DelegationProblem struct {
+	Kind       string
+	NameServer string `json:",omitempty"`
+	Message    string
+}
+
+// Problems checks the trace, and when zone is set, that zone is the one
+// delegated to the expected name servers
+func (t Trace) Problems(zone string, expected []string) []DelegationProblem {
+	var problems []DelegationProblem
+	for _, s := range t.Servers {
+		if s.Authoritative {
+			if missing, extra := CompareNameServers(t.NameServers, s.NameServers); len(missing) > 0 || len(extra) > 0 {
+				problems = append(problems, DelegationProblem{
+					Kind:       ProblemInconsistent,
+					NameServer: s.NameServer,
+					Message:    fmt.Sprintf("%s (%s) serves the NS records %v for %s, the parent zone delegates to %v", s.NameServer, s.Address, s.NameServers, t.Zone, t.NameServers),
+				})
+			}
+			continue
+		}
+		reason := s.Error
+		if reason == "" {
+			reason = "rcode " + s.Rcode
+			if s.Rcode == "NOERROR" {
+				reason = "a non-authoritative answer"
+			}
+		}
+		problems = append(problems, DelegationProblem{
+			Kind:       ProblemLame,
+			NameServer: s.NameServer,
+			Message:    fmt.Sprintf("%s (%s) is delegated %s but answers with %s", s.NameServer, s.Address, t.Zone, reason),
+		})
+	}
+	if zone == "" {
+		return problems
+	}
+	zone = dns.Fqdn(strings.ToLower(zone))
+	if zone != t.Zone {
+		return append(problems, DelegationProblem{
+			Kind:    ProblemNotDelegated,
+			Message: fmt.Sprintf("%s is not delegated, %s is served by the zone %s", zone, t.Name, t.Zone),
+		})
+	}
+	if missing, extra := CompareNameServers(expected, t.NameServers); len(missing) > 0 || len(extra) > 0 {
+		problems = append(problems, DelegationProblem{
+			Kind:    ProblemMismatch,
+			Message: fmt.Sprintf("%s is delegated to %v instead of %v", zone, t.NameServers, expected),
+		})
+	}
+	return problems
+}
@@ -0,0 +1,157 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// testNameServer answers the queries of its zones with its records, or
+// with its referrals when they delegate the name
+type testNameServer struct {
+	zones     []string
+	records   []string
+	referrals []string
+}
+
+func (s testNameServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	q := r.Question[0]
+	rrs := func(records []string) (rrs []dns.RR) {
+		for _, record := range records {
+			rr, _ := dns.NewRR(record)
+			rrs = append(rrs, rr)
+		}
+		return rrs
+	}
+	for _, rr := range rrs(s.referrals) {
+		if _, ok := rr.(*dns.NS); ok && dns.IsSubDomain(rr.Header().Name, q.Name) {
+			m.Ns = append(m.Ns, rr)
+		}
+	}
+	if len(m.Ns) > 0 {
+		// The glue records
+		for _, rr := range rrs(s.referrals) {
+			if _, ok := rr.(*dns.NS); !ok {
+				m.Extra = append(m.Extra, rr)
+			}
+		}
+		w.WriteMsg(m)
+		return
+	}
+	for _, zone := range s.zones {
+		if dns.IsSubDomain(zone, q.Name) {
+			m.Authoritative = true
+			for _, rr := range rrs(s.records) {
+				if rr.Header().Name == q.Name && rr.Header().Rrtype == q.Qtype {
+					m.Answer = append(m.Answer, rr)
+				}
+			}
+			w.WriteMsg(m)
+			return
+		}
+	}
+	m.Rcode = dns.RcodeRefused
+	w.WriteMsg(m)
+}
+
+// startTestNameServers runs a server for each address on the same port
+func startTestNameServers(t *testing.T, servers map[string]testNameServer) {
+	t.Helper()
+	port := "0"
+	for _, ip := range []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4"} {
+		pc, err := net.ListenPacket("udp", net.JoinHostPort(ip, port))
+		if err != nil {
+			t.Skip("loopback addresses are not available:", err)
+		}
+		_, port, _ = net.SplitHostPort(pc.LocalAddr().String())
+		handler, ok := servers[ip]
+		if !ok {
+			handler = testNameServer{}
+		}
+		server := &dns.Server{PacketConn: pc, Handler: handler}
+		started := make(chan struct{})
+		server.NotifyStartedFunc = func() { close(started) }
+		go server.ActivateAndServe()
+		<-started
+		t.Cleanup(func() { server.Shutdown() })
+	}
+	nameServerPort = port
+	t.Cleanup(func() { nameServerPort = "53" })
+}
+
+func TestTraceDelegation(t *testing.T) {
+	startTestNameServers(t, map[string]testNameServer{
+		// The root delegates com.
+		"127.0.0.1": {
+			zones: []string{"."},
+			referrals: []string{
+				"com. 172800 IN NS a.gtld.test.",
+				"a.gtld.test. 172800 IN A 127.0.0.2",
+			},
+		},
+		// com. delegates example.com. to ns1, which serves it, and ns2,
+		// which is lame
+		"127.0.0.2": {
+			zones: []string{"com."},
+			referrals: []string{
+				"example.com. 172800 IN NS ns1.example.com.",
+				"example.com. 172800 IN NS ns2.example.com.",
+				"ns1.example.com. 172800 IN A 127.0.0.3",
+				"ns2.example.com. 172800 IN A 127.0.0.4",
+			},
+		},
+		"127.0.0.3": {
+			zones: []string{"example.com."},
+			records: []string{
+				"example.com. 300 IN NS ns1.example.com.",
+				"example.com. 300 IN NS ns3.example.com.",
+			},
+		},
+	})
+	port := nameServerPort
+
+	trace, err := TraceDelegation(context.Background(), "www.Example.com", "127.0.0.1:"+port, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace.Zone != "example.com." || !reflect.DeepEqual(trace.NameServers, []string{"ns1.example.com.", "ns2.example.com."}) {
+		t.Errorf("unexpected delegation %s %v", trace.Zone, trace.NameServers)
+	}
+	if len(trace.Steps) != 2 || trace.Steps[0].Zone != "com." || trace.Steps[1].Server != "127.0.0.2:"+port {
+		t.Errorf("unexpected steps %+v", trace.Steps)
+	}
+	if len(trace.Servers) != 2 || !trace.Servers[0].Authoritative || trace.Servers[1].Authoritative || trace.Servers[1].Rcode != "REFUSED" {
+		t.Errorf("unexpected servers %+v", trace.Servers)
+	}
+
+	var kinds []string
+	for _, p := range trace.Problems("example.com", []string{"ns1.example.com", "ns2.example.com"}) {
+		kinds = append(kinds, p.Kind+" "+p.NameServer)
+	}
+	expected := []string{ProblemInconsistent + " ns1.example.com.", ProblemLame + " ns2.example.com."}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("expected %v, got %v", expected, kinds)
+	}
+
+	problems := trace.Problems("example.com", []string{"ns-1.awsdns-01.org", "ns-2.awsdns-02.com"})
+	if last := problems[len(problems)-1]; last.Kind != ProblemMismatch {
+		t.Errorf("expected a mismatch, got %+v", last)
+	}
+	problems = trace.Problems("www.example.com.", nil)
+	if last := problems[len(problems)-1]; last.Kind != ProblemNotDelegated || !strings.Contains(last.Message, "example.com.") {
+		t.Errorf("expected a missing delegation, got %+v", last)
+	}
+}
+
+func TestCompareNameServers(t *testing.T) {
+	missing, extra := CompareNameServers([]string{"NS-1.awsdns-01.org", "ns-2.awsdns-02.com."}, []string{"ns-2.awsdns-02.com.", "ns3.example.com."})
+	if !reflect.DeepEqual(missing, []string{"ns-1.awsdns-01.org."}) || !reflect.DeepEqual(extra, []string{"ns3.example.com."}) {
+		t.Errorf("unexpected comparison %v %v", missing, extra)
+	}
+}
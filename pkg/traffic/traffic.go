@@ -1,10 +1,15 @@
 package traffic
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 type HttpHeader struct {
@@ -25,7 +30,8 @@ type RequestUrlResponse struct {
 
 // Source: https://golangcode.com/download-a-file-from-a-url/
 // DownloadFile will download a url to a local file. It's efficient because it will
-// write as it downloads and not load the whole file into memory.
+// write as it downloads and not load the whole file into memory. The file is
+// only replaced when the download succeeds with a 200 status code.
 func DownloadFile(filepath string, url string) error {
 	log.Println("Downloading the file:", url)
 	log.Println("Filepath:", filepath)
@@ -35,23 +41,55 @@ func DownloadFile(filepath string, url string) error {
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s, status code %d", url, resp.StatusCode)
+	}
+
+	return WriteFileAtomic(filepath, func(w io.Writer) error {
+		// Write the body to file
+		_, err := io.Copy(w, resp.Body)
+		return err
+	})
+}
 
-	// Create the file
-	out, err := os.Create(filepath)
+// WriteFileAtomic writes to a temporary file next to path and renames it to
+// path once write succeeds, so readers never see a partially written file.
+func WriteFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-
-	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
-	return err
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
-func GetRequestUrlResponse(u string) http.Response {
-	resp, err := http.Get(u)
+// GetRequestUrlResponse returns the status and headers of a GET request to u,
+// the body is discarded.
+func GetRequestUrlResponse(ctx context.Context, u string) (http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return http.Response{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Println(err)
+		return http.Response{}, err
 	}
-	return *resp
+	resp.Body.Close()
+	return *resp, nil
+}
+
+// GetResponseETag returns the ETag header of resp without the surrounding
+// quotes. CloudFront weakens the origin's ETag, W/"abc", when it compresses
+// the response, the W/ prefix is removed so it compares with the origin's.
+func GetResponseETag(resp http.Response) string {
+	eTag := strings.TrimPrefix(resp.Header.Get("ETag"), "W/")
+	return strings.ReplaceAll(eTag, "\"", "")
 }
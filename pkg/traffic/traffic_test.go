@@ -0,0 +1,24 @@
+package traffic
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetResponseETag(t *testing.T) {
+	tests := map[string]string{
+		`"abc123"`:   "abc123",
+		`W/"abc123"`: "abc123",
+		`abc123`:     "abc123",
+		``:           "",
+	}
+	for header, expected := range tests {
+		resp := http.Response{Header: http.Header{}}
+		if header != "" {
+			resp.Header.Set("ETag", header)
+		}
+		if eTag := GetResponseETag(resp); eTag != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, header, eTag)
+		}
+	}
+}
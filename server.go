@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/gin-gonic/gin"
+	"github.com/unfor19/columbus-app/internal/aws/accounts"
+	awsnetwork "github.com/unfor19/columbus-app/internal/aws/network"
+	ccloudfront "github.com/unfor19/columbus-app/internal/aws/service/cloudfront"
+	"github.com/unfor19/columbus-app/internal/config"
+	"github.com/unfor19/columbus-app/internal/explorer"
+	"github.com/unfor19/columbus-app/internal/graph"
+	"github.com/unfor19/columbus-app/internal/jobs"
+	"github.com/unfor19/columbus-app/internal/remediate"
+	"github.com/unfor19/columbus-app/internal/render"
+	"github.com/unfor19/columbus-app/internal/takeover"
+	cdns "github.com/unfor19/columbus-app/pkg/dns"
+)
+
+// settings are loaded once when the server starts
+var settings = config.Default()
+
+var explorations *jobs.Manager
+
+// invalidations run in the background like explorations
+var invalidations *jobs.Manager
+
+// exploredDistribution is how an exploration reached a distribution, its
+// invalidation uses the same accounts
+type exploredDistribution struct {
+	accounts *accounts.Accounts
+	// cfg is the configuration of the account the distribution was found in
+	cfg aws.Config
+	// target is the explored URL and its S3 object, nil when the route does
+	// not end in an S3 origin
+	target  *remediate.ETagTarget
+	addedAt time.Time
+}
+
+// distributionList holds the explored distributions for ttl after they were
+// last explored
+type distributionList struct {
+	ttl time.Duration
+
+	mu            sync.Mutex
+	distributions map[string]exploredDistribution
+}
+
+func newDistributionList(ttl time.Duration) *distributionList {
+	return &distributionList{ttl: ttl, distributions: map[string]exploredDistribution{}}
+}
+
+// add keeps d for ttl, and forgets the distributions that expired
+func (l *distributionList) add(id string, d exploredDistribution) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d.addedAt = time.Now()
+	for i, explored := range l.distributions {
+		if d.addedAt.Sub(explored.addedAt) > l.ttl {
+			delete(l.distributions, i)
+		}
+	}
+	l.distributions[id] = d
+}
+
+func (l *distributionList) get(id string) (exploredDistribution, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d, ok := l.distributions[id]
+	return d, ok && time.Since(d.addedAt) <= l.ttl
+}
+
+// exploredDistributions are the distributions that explorations of this
+// server selected within the explorations retention, only they can be
+// invalidated. They are kept in memory, after a restart the domain must be
+// explored again.
+var exploredDistributions *distributionList
+
+// serverExplore explores requestUrl and records the selected distribution
+func serverExplore(ctx context.Context, config explorer.Config, requestUrl string, progress func(stage string)) (explorer.Result, error) {
+	e := explorer.New(ctx, config)
+	e.OnProgress(progress)
+	result, err := e.Explore(requestUrl)
+	if a, cfg, ok := e.DistributionAccounts(); ok {
+		explored := exploredDistribution{accounts: a, cfg: cfg}
+		if target, ok := remediate.NewETagTarget(result.AwsMapping); ok {
+			explored.target = &target
+		}
+		exploredDistributions.add(result.AwsMapping.Distribution.Id, explored)
+	}
+	return result, err
+}
+
+// responseFormat is the ?format= query parameter, or the format negotiated
+// from the Accept header.
+func responseFormat(c *gin.Context) (render.Format, error) {
+	if format := c.Query("format"); format != "" {
+		return render.ParseFormat(format)
+	}
+	return render.Negotiate(c.GetHeader("Accept")), nil
+}
+
+// exploreConfig is the default configuration with the region, principals
+// and roles of the query parameters.
+func exploreConfig(c *gin.Context) (explorer.Config, error) {
+	explorerConfig := settings.Explorer()
+	if region := c.Query("region"); region != "" {
+		explorerConfig.Region = region
+	}
+	explorerConfig.Principals = c.QueryArray("principal")
+	for _, role := range c.QueryArray("role") {
+		if err := explorerConfig.Roles.Set(role); err != nil {
+			return explorerConfig, err
+		}
+	}
+	return explorerConfig, nil
+}
+
+func getExplore(c *gin.Context) {
+	format, err := responseFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	requestUrl := c.Query("requestUrl")
+	if requestUrl == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "requestUrl is required"})
+		return
+	}
+	config, err := exploreConfig(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	result, err := serverExplore(c.Request.Context(), config, requestUrl, func(stage string) {})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		return
+	}
+	if format == render.FormatJSON {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	var b bytes.Buffer
+	if err := render.Render(&b, format, result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, format.ContentType(), b.Bytes())
+}
+
+// getGraph responds with the topology graph, as JSON nodes and edges by
+// default, or as DOT or Mermaid.
+func getGraph(c *gin.Context) {
+	format, err := responseFormat(c)
+	isGraphFormat := format == render.FormatJSON || format == render.FormatDOT || format == render.FormatMermaid
+	if err != nil || (!isGraphFormat && c.Query("format") != "") {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "format must be one of json, dot or mermaid"})
+		return
+	}
+	if !isGraphFormat {
+		// A browser accepts HTML, which has no graph export
+		format = render.FormatJSON
+	}
+	requestUrl := c.Query("requestUrl")
+	if requestUrl == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "requestUrl is required"})
+		return
+	}
+	config, err := exploreConfig(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	result, err := serverExplore(c.Request.Context(), config, requestUrl, func(stage string) {})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		return
+	}
+	if format == render.FormatJSON {
+		c.JSON(http.StatusOK, graph.New(result.AwsMapping))
+		return
+	}
+	var b bytes.Buffer
+	if err := render.Render(&b, format, result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, format.ContentType(), b.Bytes())
+}
+
+// getScan responds with the dangling records of the hosted zones that serve
+// the zone query parameter.
+func getScan(c *gin.Context) {
+	zone := c.Query("zone")
+	if zone == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "zone is required"})
+		return
+	}
+	config, err := exploreConfig(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	result, err := takeover.Scan(c.Request.Context(), config, zone)
+	if err == takeover.ErrNoHostedZone {
+		c.JSON(http.StatusNotFound, gin.H{"Error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+type explorationRequest struct {
+	RequestUrl string `json:"requestUrl" form:"requestUrl"`
+}
+
+func postExploration(c *gin.Context) {
+	var req explorationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	if req.RequestUrl == "" {
+		req.RequestUrl = c.Query("requestUrl")
+	}
+	if req.RequestUrl == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": "requestUrl is required"})
+		return
+	}
+	// The configuration of the request is captured now, the job runs later
+	config, err := exploreConfig(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	job, err := explorations.Submit(req.RequestUrl, func(ctx context.Context, progress func(stage string)) (interface{}, error) {
+		return serverExplore(ctx, config, req.RequestUrl, progress)
+	})
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"Error": err.Error()})
+		return
+	}
+	c.Header("Location", "/explorations/"+job.Id)
+	c.JSON(http.StatusAccepted, job)
+}
+
+func getExploration(c *gin.Context) {
+	job, err := explorations.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"Error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func deleteExploration(c *gin.Context) {
+	job, err := explorations.Cancel(c.Param("id"))
+	switch err {
+	case nil:
+		c.JSON(http.StatusAccepted, job)
+	case jobs.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"Error": err.Error()})
+	default:
+		c.JSON(http.StatusConflict, gin.H{"Error": err.Error(), "Job": job})
+	}
+}
+
+// postInvalidate submits the invalidation as a job, as waiting for it to
+// complete and for the served ETag to change takes minutes. The invalidation
+// is created only for a distribution that an exploration of this server
+// selected, with the accounts and roles of that exploration. The served ETag
+// is compared for the URL that the exploration resolved, the client only
+// chooses the paths.
+func postInvalidate(c *gin.Context) {
+	var req remediate.InvalidationRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	if err := remediate.Validate(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	explored, ok := exploredDistributions.get(req.DistributionId)
+	if !ok && !req.IsDryRun() {
+		c.JSON(http.StatusForbidden, gin.H{"Error": "distribution " + req.DistributionId + " was not selected by a recent exploration of this server, explore it first"})
+		return
+	}
+	req.Target = explored.target
+	var requestUrl string
+	if req.Target != nil {
+		requestUrl = req.Target.RequestUrl
+	}
+	opts := remediate.PollOptions{
+		Interval: time.Duration(settings.Timeouts.InvalidationInterval),
+		Timeout:  time.Duration(settings.Timeouts.Invalidation),
+	}
+	job, err := invalidations.Submit(requestUrl, func(ctx context.Context, progress func(stage string)) (interface{}, error) {
+		progress("Invalidating " + req.DistributionId)
+		var bucketConfig ccloudfront.BucketConfigFunc
+		if explored.accounts != nil {
+			bucketConfig = explored.accounts.Bucket
+		}
+		return remediate.Invalidate(ctx, explored.cfg, bucketConfig, req, opts)
+	})
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"Error": err.Error()})
+		return
+	}
+	c.Header("Location", "/remediate/invalidations/"+job.Id)
+	c.JSON(http.StatusAccepted, job)
+}
+
+func getInvalidation(c *gin.Context) {
+	job, err := invalidations.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"Error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func serve(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := flags.String("config", "", "Configuration file, YAML or JSON, defaults to COLUMBUS_CONFIG")
+	listen := flags.String("listen", "", "Address to listen on, overrides the configuration")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	var err error
+	if settings, err = config.Load(*configPath); err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+	if *listen != "" {
+		settings.Listen = *listen
+		if err := settings.Validate(); err != nil {
+			log.Println(err)
+			return exitUsage
+		}
+	}
+	if settings.PublicSuffixList != "" {
+		if err := cdns.LoadPublicSuffixList(settings.PublicSuffixList); err != nil {
+			log.Println(err)
+			return exitUsage
+		}
+	}
+
+	log.Println("Starting server ...")
+	if os.Getenv("GO_GIN_DEBUG") != "true" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	explorations = jobs.NewManager(settings.Explorations.Workers, settings.Explorations.QueueSize, time.Duration(settings.Explorations.Retention))
+	exploredDistributions = newDistributionList(time.Duration(settings.Explorations.Retention))
+	defer explorations.Shutdown()
+	invalidations = jobs.NewManager(settings.Invalidations.Workers, settings.Invalidations.QueueSize, time.Duration(settings.Invalidations.Retention))
+	defer invalidations.Shutdown()
+
+	// Keep the AWS ip ranges fresh between explorations
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go awsnetwork.SharedIpRangesManager(settings.IpRanges.FilePath, settings.IpRanges.Url, time.Duration(settings.IpRanges.RefreshInterval)).Run(ctx)
+
+	r := gin.Default()
+	r.GET("/explore", getExplore)
+	r.GET("/graph", getGraph)
+	r.GET("/scan", getScan)
+	r.POST("/explorations", postExploration)
+	r.GET("/explorations/:id", getExploration)
+	r.DELETE("/explorations/:id", deleteExploration)
+	r.POST("/remediate/invalidate", postInvalidate)
+	r.GET("/remediate/invalidations/:id", getInvalidation)
+
+	r.GET("/", func(c *gin.Context) {
+		response := "Use the following query http://localhost:8080/explore?requestUrl=https://dev.api.sokker.info"
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Data(200, "Content-Type: text/plain; charset=utf-8", []byte(response))
+	})
+	// listen and serve on 0.0.0.0:8080 (for windows "localhost:8080")
+	if err := r.Run(settings.Listen); err != nil {
+		log.Println(err)
+		return exitError
+	}
+	return exitOk
+}